@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/go-cinch/cinch/cmd/cinch/internal/base"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/gorm"
 	"github.com/go-cinch/common/utils"
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
 const (
@@ -15,6 +17,9 @@ const (
 	DefaultModule = ""
 	DefaultApi    = ""
 	DefaultCover  = false
+	DefaultConfig = "configs/gen.yml"
+	DefaultTable  = ""
+	DefaultSource = ""
 )
 
 var CmdBiz = &cobra.Command{
@@ -29,6 +34,9 @@ func init() {
 	CmdBiz.PersistentFlags().StringP("module", "m", DefaultModule, "module name")
 	CmdBiz.PersistentFlags().StringP("api", "a", DefaultApi, "api name(default same as module)")
 	CmdBiz.PersistentFlags().BoolP("cover", "c", DefaultCover, "cover old file or not")
+	CmdBiz.PersistentFlags().StringP("config", "", DefaultConfig, "gen.yml path, soft-delete of it adds Restore/ForceDelete, optimistic-lock of it adds version to Update")
+	CmdBiz.PersistentFlags().StringP("table", "t", DefaultTable, "table name of soft-delete and optimistic-lock in gen.yml(default same as api)")
+	CmdBiz.PersistentFlags().StringP("source", "", DefaultSource, "source name of gen.yml sources which generates table, required only if sources set soft-delete or optimistic-lock of table differently")
 }
 
 func run(cmd *cobra.Command, _ []string) {
@@ -36,6 +44,9 @@ func run(cmd *cobra.Command, _ []string) {
 	module, _ := cmd.Flags().GetString("module")
 	api, _ := cmd.Flags().GetString("api")
 	cover, _ := cmd.Flags().GetBool("cover")
+	config, _ := cmd.Flags().GetString("config")
+	table, _ := cmd.Flags().GetString("table")
+	source, _ := cmd.Flags().GetString("source")
 
 	var err error
 	if module == DefaultModule {
@@ -48,6 +59,19 @@ func run(cmd *cobra.Command, _ []string) {
 	if api == DefaultApi {
 		api = module
 	}
+	if table == DefaultTable {
+		table = api
+	}
+	_, withSoftDelete, err := gorm.TableSoftDelete(config, source, table)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot read soft-delete of %s: %s\033[m\n", config, err.Error())
+		return
+	}
+	version, withVersion, err := gorm.TableVersion(config, source, table)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot read optimistic-lock of %s: %s\033[m\n", config, err.Error())
+		return
+	}
 	if dir == DefaultPath {
 		dir = fmt.Sprintf("internal/biz/%s.go", api)
 	}
@@ -77,6 +101,20 @@ func run(cmd *cobra.Command, _ []string) {
 
 	camelApi := utils.CamelCase(api)
 
+	var updateVersion, repoSoftDelete string
+	if withVersion {
+		// the version read by the caller, it is not compared as a changed field
+		updateVersion = fmt.Sprintf("\t%v int64 `json:\"-\"`\n", version.Field())
+		err = GenConflictError(fileDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot create ErrDataConflict: %s\033[m\n", err.Error())
+			return
+		}
+	}
+	if withSoftDelete {
+		repoSoftDelete = "\tRestore(ctx context.Context, ids ...uint64) error\n\tForceDelete(ctx context.Context, ids ...uint64) error\n"
+	}
+
 	content := fmt.Sprintf(`package biz
 
 import (
@@ -110,7 +148,7 @@ type Find%vCache struct {
 type Update%v struct {
 	Id   uint64  %vjson:"id,string"%v
 	Name *string %vjson:"name,omitempty"%v
-}
+%v}
 
 type %vRepo interface {
 	Create(ctx context.Context, item *%v) error
@@ -118,7 +156,7 @@ type %vRepo interface {
 	Find(ctx context.Context, condition *Find%v) []%v
 	Update(ctx context.Context, item *Update%v) error
 	Delete(ctx context.Context, ids ...uint64) error
-}
+%v}
 
 type %vUseCase struct {
 	c     *conf.Bootstrap
@@ -228,8 +266,8 @@ func (uc *%vUseCase) Delete(ctx context.Context, ids ...uint64) error {
 		"`", "`", camelApi, "`", "`",
 		camelApi, "`", "`", camelApi, "`",
 
-		"`", "`", "`", camelApi, camelApi,
-		camelApi, camelApi, camelApi, camelApi, camelApi,
+		"`", "`", "`", updateVersion, camelApi, camelApi,
+		camelApi, camelApi, camelApi, camelApi, repoSoftDelete, camelApi,
 
 		camelApi, camelApi, camelApi, camelApi, camelApi,
 		camelApi, camelApi, camelApi, camelApi, camelApi,
@@ -239,6 +277,29 @@ func (uc *%vUseCase) Delete(ctx context.Context, ids ...uint64) error {
 
 		camelApi, camelApi, camelApi,
 	)
+	if withSoftDelete {
+		content += fmt.Sprintf(`
+func (uc *%vUseCase) Restore(ctx context.Context, ids ...uint64) error {
+	return uc.tx.Tx(ctx, func(ctx context.Context) error {
+		return uc.cache.Flush(ctx, func(ctx context.Context) (err error) {
+			err = uc.repo.Restore(ctx, ids...)
+			return
+		})
+	})
+}
+
+func (uc *%vUseCase) ForceDelete(ctx context.Context, ids ...uint64) error {
+	return uc.tx.Tx(ctx, func(ctx context.Context) error {
+		return uc.cache.Flush(ctx, func(ctx context.Context) (err error) {
+			err = uc.repo.ForceDelete(ctx, ids...)
+			return
+		})
+	})
+}
+`,
+			camelApi, camelApi,
+		)
+	}
 
 	_, err = f.Write([]byte(content))
 	if err != nil {
@@ -250,3 +311,36 @@ func (uc *%vUseCase) Delete(ctx context.Context, ids ...uint64) error {
 
 	fmt.Printf("\n🍺 Generate biz file success: %s\n", color.GreenString(dir))
 }
+
+var conflictErrorRegexp = regexp.MustCompile(`func\s+ErrDataConflict\s*\(|\bErrDataConflict\s*=`)
+
+// GenConflictError writes ErrDataConflict into dir if no file of dir declares it, it is returned by Update of optimistic-lock
+func GenConflictError(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	for _, item := range files {
+		b, e := os.ReadFile(item)
+		if e != nil {
+			return e
+		}
+		if conflictErrorRegexp.Match(b) {
+			return nil
+		}
+	}
+	content := `package biz
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+// ErrDataConflict is returned by Update if the record is changed by others after the caller read it
+func ErrDataConflict(_ context.Context) error {
+	return errors.Conflict("DATA_CONFLICT", "data is changed by others, please reload and retry")
+}
+`
+	return os.WriteFile(filepath.Join(dir, "conflict.go"), []byte(content), 0644)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/go-cinch/cinch/cmd/cinch/internal/base"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/biz"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/gorm"
 	"github.com/go-cinch/common/utils"
 	"github.com/spf13/cobra"
 	"os"
@@ -15,6 +17,9 @@ const (
 	DefaultModule = ""
 	DefaultApi    = ""
 	DefaultCover  = false
+	DefaultConfig = "configs/gen.yml"
+	DefaultTable  = ""
+	DefaultSource = ""
 )
 
 var CmdData = &cobra.Command{
@@ -29,6 +34,9 @@ func init() {
 	CmdData.PersistentFlags().StringP("module", "m", DefaultModule, "module name")
 	CmdData.PersistentFlags().StringP("api", "a", DefaultApi, "api name(default same as module)")
 	CmdData.PersistentFlags().BoolP("cover", "c", DefaultCover, "cover old file or not")
+	CmdData.PersistentFlags().StringP("config", "", DefaultConfig, "gen.yml path, soft-delete of it generates Restore/ForceDelete, optimistic-lock of it adds version predicate to Update")
+	CmdData.PersistentFlags().StringP("table", "t", DefaultTable, "table name of soft-delete and optimistic-lock in gen.yml(default same as api)")
+	CmdData.PersistentFlags().StringP("source", "", DefaultSource, "source name of gen.yml sources which generates table, required only if sources set soft-delete or optimistic-lock of table differently")
 }
 
 func run(cmd *cobra.Command, _ []string) {
@@ -36,6 +44,9 @@ func run(cmd *cobra.Command, _ []string) {
 	module, _ := cmd.Flags().GetString("module")
 	api, _ := cmd.Flags().GetString("api")
 	cover, _ := cmd.Flags().GetBool("cover")
+	config, _ := cmd.Flags().GetString("config")
+	table, _ := cmd.Flags().GetString("table")
+	source, _ := cmd.Flags().GetString("source")
 
 	var err error
 	if module == DefaultModule {
@@ -48,6 +59,19 @@ func run(cmd *cobra.Command, _ []string) {
 	if api == DefaultApi {
 		api = module
 	}
+	if table == DefaultTable {
+		table = api
+	}
+	softDelete, withSoftDelete, err := gorm.TableSoftDelete(config, source, table)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot read soft-delete of %s: %s\033[m\n", config, err.Error())
+		return
+	}
+	version, withVersion, err := gorm.TableVersion(config, source, table)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot read optimistic-lock of %s: %s\033[m\n", config, err.Error())
+		return
+	}
 	if dir == DefaultPath {
 		dir = fmt.Sprintf("internal/data/%s.go", api)
 	}
@@ -76,6 +100,34 @@ func run(cmd *cobra.Command, _ []string) {
 	}
 
 	camelApi := utils.CamelCase(api)
+	bizDir := filepath.Join(filepath.Dir(filepath.Clean(fileDir)), "biz")
+	if withVersion {
+		err = biz.GenConflictError(bizDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot create ErrDataConflict in %s: %s\033[m\n", bizDir, err.Error())
+			return
+		}
+	}
+
+	update := `	_, err = db.
+		Where(p.ID.Eq(item.Id)).
+		Updates(&change)
+	return`
+	if withVersion {
+		// version is increased by optimisticlock, the caller read the record of item version,
+		// zero rows affected means the record is changed by others after that
+		update = fmt.Sprintf(`	var info gen.ResultInfo
+	info, err = db.
+		Where(p.ID.Eq(item.Id), p.%v.Eq(item.%v)).
+		Updates(&change)
+	if err != nil {
+		return
+	}
+	if info.RowsAffected == 0 {
+		err = biz.ErrDataConflict(ctx)
+	}
+	return`, version.Field(), version.Field())
+	}
 
 	content := fmt.Sprintf(`package data
 
@@ -175,10 +227,7 @@ func (ro %vRepo) Update(ctx context.Context, item *biz.Update%v) (err error) {
 			return
 		}
 	}
-	_, err = db.
-		Where(p.ID.Eq(item.Id)).
-		Updates(&change)
-	return
+%v
 }
 
 func (ro %vRepo) Delete(ctx context.Context, ids ...uint64) (err error) {
@@ -213,9 +262,36 @@ func (ro %vRepo) NameExists(ctx context.Context, name string) (err error) {
 		camelApi, api, camelApi, camelApi, camelApi,
 		api, camelApi, camelApi, camelApi, camelApi,
 
-		camelApi, api, camelApi, camelApi, api,
-		camelApi, api, camelApi, "`", "`",
+		camelApi, api, camelApi, camelApi, update,
+		api, camelApi, api, camelApi, "`",
+		"`",
 	)
+	if withSoftDelete {
+		content += fmt.Sprintf(`
+func (ro %vRepo) Restore(ctx context.Context, ids ...uint64) (err error) {
+	p := query.Use(ro.data.DB(ctx)).%v
+	db := p.WithContext(ctx)
+	_, err = db.
+		Unscoped().
+		Where(p.ID.In(ids...)).
+		Update(p.%v, %v)
+	return
+}
+
+func (ro %vRepo) ForceDelete(ctx context.Context, ids ...uint64) (err error) {
+	p := query.Use(ro.data.DB(ctx)).%v
+	db := p.WithContext(ctx)
+	_, err = db.
+		Unscoped().
+		Where(p.ID.In(ids...)).
+		Delete()
+	return
+}
+`,
+			api, camelApi, softDelete.Field(), softDelete.Restored(),
+			api, camelApi,
+		)
+	}
 
 	_, err = f.Write([]byte(content))
 	if err != nil {
@@ -226,4 +302,14 @@ func (ro %vRepo) NameExists(ctx context.Context, name string) (err error) {
 	base.Lint(fileDir)
 
 	fmt.Printf("\n🍺 Generate data file success: %s\n", color.GreenString(dir))
+	if withSoftDelete || withVersion {
+		fmt.Println(color.YellowString("biz of %v needs the following, generate biz with the same config or add them by hand:", api))
+	}
+	if withSoftDelete {
+		fmt.Printf("\tRestore(ctx context.Context, ids ...uint64) error // method of biz.%vRepo\n", camelApi)
+		fmt.Printf("\tForceDelete(ctx context.Context, ids ...uint64) error // method of biz.%vRepo\n", camelApi)
+	}
+	if withVersion {
+		fmt.Printf("\t%v int64 `json:\"-\"` // field of biz.Update%v\n", version.Field(), camelApi)
+	}
 }
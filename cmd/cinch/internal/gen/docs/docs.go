@@ -0,0 +1,106 @@
+package docs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/gorm"
+	"github.com/go-cinch/common/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DefaultConfig  = "configs/gen.yml"
+	DefaultSource  = ""
+	DefaultOut     = "docs/db"
+	DefaultDiagram = diagramMermaid
+)
+
+const (
+	diagramMermaid  = "mermaid"
+	diagramGraphviz = "graphviz"
+)
+
+var CmdDocs = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate ER diagram and data dictionary. Example: cinch gen docs -o docs/db",
+	Long:  "Generate ER diagram(mermaid or graphviz) and markdown data dictionary of tables selected by gen.yml, associations of gen.yml are the relationships. Example: cinch gen docs -o docs/db --diagram graphviz",
+	Run:   run,
+}
+
+func init() {
+	CmdDocs.PersistentFlags().StringP("config", "c", DefaultConfig, "gen.yml path, dsn/db/tables/exclude/association/sources of it are used as gen gorm does")
+	CmdDocs.PersistentFlags().StringP("source", "", DefaultSource, "enter the source names of gen.yml sources or leave it blank to generate all. Example: --source \"primary,analytics\"")
+	CmdDocs.PersistentFlags().StringP("out", "o", DefaultOut, "output directory, every source has a markdown file and a diagram file")
+	CmdDocs.PersistentFlags().StringP("diagram", "", DefaultDiagram, "input mermaid|graphviz, mermaid diagram is also embedded into markdown")
+}
+
+func run(cmd *cobra.Command, _ []string) {
+	source, _ := cmd.Flags().GetString("source")
+	out, _ := cmd.Flags().GetString("out")
+	diagram, _ := cmd.Flags().GetString("diagram")
+
+	if !utils.Contains[string]([]string{diagramMermaid, diagramGraphviz}, diagram) {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: invalid diagram %s, support mermaid || graphviz\033[m\n", diagram)
+		return
+	}
+	names := make([]string, 0)
+	for _, item := range strings.Split(source, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			names = append(names, item)
+		}
+	}
+	tables, err := gorm.Inspect(cmd, names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: inspect tables fail: %s\033[m\n", err.Error())
+		return
+	}
+	err = os.MkdirAll(out, 0750)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: cannot create dir %s: %s\033[m\n", out, err.Error())
+		return
+	}
+
+	sources := make([]string, 0)
+	bySource := make(map[string][]gorm.Table)
+	for _, item := range tables {
+		if _, ok := bySource[item.Source]; !ok {
+			sources = append(sources, item.Source)
+		}
+		bySource[item.Source] = append(bySource[item.Source], item)
+	}
+	for _, item := range sources {
+		name := item
+		if name == "" {
+			name = "schema"
+		}
+		list := bySource[item]
+		diagramFile, diagramContent := name+".mmd", mermaid(list)
+		if diagram == diagramGraphviz {
+			diagramFile, diagramContent = name+".dot", graphviz(list)
+		}
+		err = writeFile(filepath.Join(out, name+".md"), markdown(item, list, diagram))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mERROR: %s\033[m\n", err.Error())
+			return
+		}
+		err = writeFile(filepath.Join(out, diagramFile), diagramContent)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mERROR: %s\033[m\n", err.Error())
+			return
+		}
+	}
+	fmt.Printf("\n🍺 Generate docs of %d tables succeeded\n", len(tables))
+}
+
+func writeFile(file, content string) (err error) {
+	err = os.WriteFile(file, []byte(content), 0640)
+	if err != nil {
+		return fmt.Errorf("cannot write %s: %w", file, err)
+	}
+	fmt.Printf("write %s\n", color.GreenString(file))
+	return
+}
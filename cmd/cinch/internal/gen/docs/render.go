@@ -0,0 +1,322 @@
+package docs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/gorm"
+	"github.com/go-cinch/common/utils"
+	"gorm.io/gen/field"
+	"gorm.io/gorm/schema"
+)
+
+const header = "Code generated by cinch gen docs. DO NOT EDIT."
+
+// mermaidName replaces the characters which are not allowed by mermaid entity and attribute
+var mermaidName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// mermaidTypeArgs matches the length, precision and enum values of column type
+var mermaidTypeArgs = regexp.MustCompile(`\(.*?\)`)
+
+// relationship is an edge of ER diagram, from owns the association field
+type relationship struct {
+	from  string
+	to    string
+	label string
+	typ   string
+}
+
+// relationships returns the associations between tables of list,
+// the belongs_to and has_many of the same foreign key are drawn once, so are the two sides of many_to_many
+func relationships(list []gorm.Table) (rp []relationship) {
+	tables := make([]string, 0, len(list))
+	for _, item := range list {
+		tables = append(tables, item.Name)
+	}
+	keys := make([]string, 0)
+	for _, item := range list {
+		for _, a := range item.Associations {
+			if !utils.Contains[string](tables, a.Relation) {
+				continue
+			}
+			var key string
+			switch a.Type {
+			case string(field.BelongsTo):
+				key = fmt.Sprintf("%s|%s|%s", a.Table, a.Relation, gormTag(a, "foreignKey"))
+			case string(field.HasOne), string(field.HasMany):
+				key = fmt.Sprintf("%s|%s|%s", a.Relation, a.Table, gormTag(a, "foreignKey"))
+			default:
+				pair := []string{a.Table, a.Relation}
+				sort.Strings(pair)
+				key = fmt.Sprintf("%s|%s|%s", pair[0], pair[1], a.JoinTable)
+			}
+			if utils.Contains[string](keys, key) {
+				continue
+			}
+			keys = append(keys, key)
+			rp = append(rp, relationship{from: a.Table, to: a.Relation, label: a.Field, typ: a.Type})
+		}
+	}
+	return
+}
+
+// gormTag returns the value of key in gorm tag of a, key is case-insensitive as gorm does
+func gormTag(a gorm.Association, key string) string {
+	for k, v := range a.Gorm {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// columnKeys returns PK/FK/UK of every column by table, FK is the foreignKey of belongs_to/has_one/has_many
+func columnKeys(list []gorm.Table) map[string]map[string][]string {
+	ns := schema.NamingStrategy{}
+	foreignKeys := make(map[string][]string)
+	for _, item := range list {
+		for _, a := range item.Associations {
+			fk := gormTag(a, "foreignKey")
+			switch {
+			case fk == "":
+			case a.Type == string(field.BelongsTo):
+				foreignKeys[a.Table] = append(foreignKeys[a.Table], fk)
+			case a.Type == string(field.HasOne), a.Type == string(field.HasMany):
+				foreignKeys[a.Relation] = append(foreignKeys[a.Relation], fk)
+			}
+		}
+	}
+	rp := make(map[string]map[string][]string)
+	for _, item := range list {
+		keys := make(map[string][]string)
+		for _, col := range item.Columns {
+			if col.PrimaryKey {
+				keys[col.Name] = append(keys[col.Name], "PK")
+			}
+			if utils.Contains[string](foreignKeys[item.Name], ns.SchemaName(col.Name)) {
+				keys[col.Name] = append(keys[col.Name], "FK")
+			}
+		}
+		for _, idx := range item.Indexes {
+			// migrator of some drivers only marks the first column of composite primary key
+			if idx.Primary {
+				for _, col := range idx.Columns {
+					if !utils.Contains[string](keys[col], "PK") {
+						keys[col] = append([]string{"PK"}, keys[col]...)
+					}
+				}
+				continue
+			}
+			if idx.Unique && len(idx.Columns) == 1 && !utils.Contains[string](keys[idx.Columns[0]], "UK") {
+				keys[idx.Columns[0]] = append(keys[idx.Columns[0]], "UK")
+			}
+		}
+		rp[item.Name] = keys
+	}
+	return rp
+}
+
+// mermaid returns the mermaid erDiagram of list
+func mermaid(list []gorm.Table) string {
+	var b strings.Builder
+	b.WriteString("%% " + header + "\n")
+	b.WriteString("erDiagram\n")
+	keys := columnKeys(list)
+	for _, item := range list {
+		fmt.Fprintf(&b, "    %s {\n", mermaidName.ReplaceAllString(item.Name, "_"))
+		for _, col := range item.Columns {
+			// length and enum values are omitted, the data dictionary has full type
+			typ := strings.Trim(mermaidName.ReplaceAllString(mermaidTypeArgs.ReplaceAllString(col.Type, ""), "_"), "_")
+			if typ == "" {
+				typ = "unknown"
+			}
+			line := fmt.Sprintf("        %s %s", typ, mermaidName.ReplaceAllString(col.Name, "_"))
+			if k := keys[item.Name][col.Name]; len(k) > 0 {
+				line += " " + strings.Join(k, ",")
+			}
+			if col.Comment != "" {
+				line += fmt.Sprintf(" %q", strings.ReplaceAll(strings.ReplaceAll(col.Comment, `"`, "'"), "\n", " "))
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, r := range relationships(list) {
+		// cardinality is read from the left entity to the right one
+		cardinality := "}o--o{"
+		switch r.typ {
+		case string(field.BelongsTo):
+			cardinality = "}o--||"
+		case string(field.HasOne):
+			cardinality = "||--o|"
+		case string(field.HasMany):
+			cardinality = "||--o{"
+		}
+		fmt.Fprintf(&b, "    %s %s %s : %q\n", mermaidName.ReplaceAllString(r.from, "_"), cardinality, mermaidName.ReplaceAllString(r.to, "_"), r.label)
+	}
+	return b.String()
+}
+
+// graphviz returns the graphviz dot of list, table is a record node and association is a crow's foot edge
+func graphviz(list []gorm.Table) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`, "\n", " ")
+	var b strings.Builder
+	b.WriteString("// " + header + "\n")
+	b.WriteString("digraph er {\n")
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [shape=record, fontname=\"Helvetica\", fontsize=10];\n")
+	b.WriteString("    edge [dir=both, fontname=\"Helvetica\", fontsize=9];\n")
+	keys := columnKeys(list)
+	for _, item := range list {
+		rows := make([]string, 0, len(item.Columns))
+		for _, col := range item.Columns {
+			row := fmt.Sprintf("%s : %s", col.Name, col.Type)
+			if k := keys[item.Name][col.Name]; len(k) > 0 {
+				row += fmt.Sprintf(" (%s)", strings.Join(k, ","))
+			}
+			rows = append(rows, escape.Replace(row)+`\l`)
+		}
+		title := item.Name
+		if item.View {
+			title += " (view)"
+		}
+		fmt.Fprintf(&b, "    %q [label=\"{%s|%s}\"];\n", item.Name, escape.Replace(title), strings.Join(rows, ""))
+	}
+	for _, r := range relationships(list) {
+		tail, head := "crow", "crow"
+		switch r.typ {
+		case string(field.BelongsTo):
+			tail, head = "crow", "tee"
+		case string(field.HasOne):
+			tail, head = "tee", "tee"
+		case string(field.HasMany):
+			tail, head = "tee", "crow"
+		}
+		fmt.Fprintf(&b, "    %q -> %q [label=%q, arrowtail=%s, arrowhead=%s];\n", r.from, r.to, r.label, tail, head)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// markdown returns the data dictionary of list, mermaid diagram is embedded so that it is rendered by GitHub/GitLab
+func markdown(source string, list []gorm.Table, diagram string) string {
+	cell := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	name := source
+	if name == "" {
+		name = "schema"
+	}
+	var b strings.Builder
+	b.WriteString("<!-- " + header + " -->\n\n")
+	if source != "" {
+		fmt.Fprintf(&b, "# Data dictionary of %s\n\n", source)
+	} else {
+		b.WriteString("# Data dictionary\n\n")
+	}
+
+	b.WriteString("## ER diagram\n\n")
+	if diagram == diagramMermaid {
+		b.WriteString("```mermaid\n" + mermaid(list) + "```\n\n")
+	} else {
+		fmt.Fprintf(&b, "See [%s.dot](%s.dot), render it by `dot -Tsvg %s.dot -o %s.svg`.\n\n", name, name, name, name)
+	}
+
+	b.WriteString("## Tables\n\n")
+	b.WriteString("| Table | Model | Comment |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, item := range list {
+		title := item.Name
+		if item.View {
+			title += " (view)"
+		}
+		fmt.Fprintf(&b, "| [%s](#%s) | %s | %s |\n", cell.Replace(title), anchor(title), item.Model, cell.Replace(item.Comment))
+	}
+	b.WriteString("\n")
+
+	keys := columnKeys(list)
+	for _, item := range list {
+		title := item.Name
+		if item.View {
+			title += " (view)"
+		}
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		if item.Comment != "" {
+			b.WriteString(cell.Replace(item.Comment) + "\n\n")
+		}
+		fmt.Fprintf(&b, "Model: `%s`\n\n", item.Model)
+
+		b.WriteString("| Column | Type | Nullable | Default | Key | Comment |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+		for _, col := range item.Columns {
+			nullable := "NO"
+			if col.Nullable {
+				nullable = "YES"
+			}
+			def := ""
+			if col.Default != nil {
+				def = "`" + *col.Default + "`"
+				if *col.Default == "" {
+					def = "`''`"
+				}
+			}
+			fmt.Fprintf(
+				&b, "| %s | %s | %s | %s | %s | %s |\n",
+				cell.Replace(col.Name), cell.Replace(col.Type), nullable, cell.Replace(def),
+				strings.Join(keys[item.Name][col.Name], ", "), cell.Replace(col.Comment),
+			)
+		}
+		b.WriteString("\n")
+
+		if len(item.Indexes) > 0 {
+			b.WriteString("### Indexes\n\n")
+			b.WriteString("| Name | Columns | Unique | Primary |\n")
+			b.WriteString("| --- | --- | --- | --- |\n")
+			for _, idx := range item.Indexes {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", cell.Replace(idx.Name), cell.Replace(strings.Join(idx.Columns, ", ")), yesNo(idx.Unique), yesNo(idx.Primary))
+			}
+			b.WriteString("\n")
+		}
+
+		if len(item.Associations) > 0 {
+			b.WriteString("### Associations\n\n")
+			b.WriteString("| Field | Type | Relation | Gorm |\n")
+			b.WriteString("| --- | --- | --- | --- |\n")
+			for _, a := range item.Associations {
+				tags := make([]string, 0, len(a.Gorm))
+				for k, v := range a.Gorm {
+					tags = append(tags, k+":"+v)
+				}
+				sort.Strings(tags)
+				relation := fmt.Sprintf("[%s](#%s)", a.Relation, anchor(a.Relation))
+				if a.JoinTable != "" {
+					relation += fmt.Sprintf(" through [%s](#%s)", a.JoinTable, anchor(a.JoinTable))
+				}
+				fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", a.Field, a.Type, relation, cell.Replace(strings.Join(tags, ";")))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// anchor returns the heading anchor of GitHub/GitLab markdown
+func anchor(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func yesNo(ok bool) string {
+	if ok {
+		return "YES"
+	}
+	return "NO"
+}
@@ -3,6 +3,7 @@ package gen
 import (
 	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/biz"
 	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/data"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/docs"
 	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/gorm"
 	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/migrate"
 	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/proto"
@@ -25,4 +26,5 @@ func init() {
 	CmdGen.AddCommand(service.CmdService)
 	CmdGen.AddCommand(biz.CmdBiz)
 	CmdGen.AddCommand(data.CmdData)
+	CmdGen.AddCommand(docs.CmdDocs)
 }
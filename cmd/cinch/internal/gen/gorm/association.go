@@ -0,0 +1,353 @@
+package gorm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+// Association is a relation between two tables. In gen.yml it is a mapping:
+//
+//	association:
+//	  - table: user
+//	    relation: role
+//	    field: Role
+//	    type: belongs_to
+//	    gorm:
+//	      foreignKey: RoleID
+//	      references: ID
+//	    json: role
+//	    preload: [Permissions]
+//
+// the legacy string "user|role|Role|has_one|foreignKey:RoleID" is accepted too.
+type Association struct {
+	// Table is the table which owns the field
+	Table string `yaml:"table,omitempty"`
+	// Relation is the related table
+	Relation string `yaml:"relation,omitempty"`
+	// Field is the go field name in the Table model
+	Field string `yaml:"field,omitempty"`
+	// Type is has_one/has_many/belongs_to/many_to_many
+	Type string `yaml:"type,omitempty"`
+	// Gorm is the gorm tag of the field, Example: foreignKey: RoleID
+	Gorm map[string]string `yaml:"gorm,omitempty"`
+	// JoinTable is the join table of many_to_many
+	JoinTable string `yaml:"join-table,omitempty"`
+	// JSON is the json tag of the field, default is Relation in lower camel case
+	JSON string `yaml:"json,omitempty"`
+	// Preload is the field names of Relation's associations, they are generated into the relation model,
+	// so that nested preload such as Preload(u.Role.Permissions) works
+	Preload []string `yaml:"preload,omitempty"`
+
+	// file and line are the position in gen.yml or flag, used by error messages
+	file  string
+	line  int
+	lines map[string]int
+}
+
+var relationshipTypes = []string{
+	string(field.HasOne),
+	string(field.HasMany),
+	string(field.BelongsTo),
+	string(field.Many2Many),
+}
+
+// gormRelationTagKeys are the gorm tag keys about association, consult[https://gorm.io/docs/associations.html#tags]
+var gormRelationTagKeys = []string{
+	"foreignKey",
+	"references",
+	"polymorphic",
+	"polymorphicValue",
+	"many2many",
+	"joinForeignKey",
+	"joinReferences",
+	"constraint",
+}
+
+var associationKeys = []string{"table", "relation", "field", "type", "gorm", "join-table", "json", "preload"}
+
+func (a Association) gormTag() field.GormTag {
+	tag := field.GormTag{}
+	for k, v := range a.Gorm {
+		tag.Set(k, v)
+	}
+	if a.JoinTable != "" {
+		tag.Set("many2many", a.JoinTable)
+	}
+	return tag
+}
+
+func (a Association) jsonTag() string {
+	if a.JSON != "" {
+		return a.JSON
+	}
+	// json tag use camel case
+	return utils.CamelCaseLowerFirst(a.Relation)
+}
+
+// errorf returns error with the position of key
+func (a Association) errorf(key, format string, args ...interface{}) error {
+	line := a.line
+	if l, ok := a.lines[key]; ok {
+		line = l
+	}
+	return fmt.Errorf("%s:%d: invalid association: %s", a.file, line, fmt.Sprintf(format, args...))
+}
+
+// parseAssociations reads associations from gen.yml,
+// the --association flag is used if gen.yml has no association
+func parseAssociations(configPath string) (list []Association, err error) {
+	list = make([]Association, 0)
+	var node *yaml.Node
+	if configPath != "" {
+		node, err = readConfigNode(configPath, "gen", "association")
+		if err != nil {
+			return
+		}
+	}
+	if node != nil {
+		return associationsFromNode(node, configPath)
+	}
+	for i, item := range strings.Split(association, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var a Association
+		a, err = parseLegacyAssociation(item, "--association", i+1)
+		if err != nil {
+			return
+		}
+		list = append(list, a)
+	}
+	err = checkPreload(list)
+	return
+}
+
+// associationsFromNode decodes the association list node of file
+func associationsFromNode(node *yaml.Node, file string) (list []Association, err error) {
+	list = make([]Association, 0)
+	if node.Kind != yaml.SequenceNode {
+		err = fmt.Errorf("%s:%d: association must be a list", file, node.Line)
+		return
+	}
+	for _, item := range node.Content {
+		var a Association
+		switch item.Kind {
+		case yaml.ScalarNode:
+			a, err = parseLegacyAssociation(item.Value, file, item.Line)
+		case yaml.MappingNode:
+			a, err = decodeAssociation(item, file)
+		default:
+			err = fmt.Errorf("%s:%d: invalid association: must be a mapping or string", file, item.Line)
+		}
+		if err != nil {
+			return
+		}
+		list = append(list, a)
+	}
+	err = checkPreload(list)
+	return
+}
+
+// parseLegacyAssociation parses "table|relation|field|type|gormTag", gormTag can be "key:value;key2:value2"
+func parseLegacyAssociation(item, file string, line int) (a Association, err error) {
+	a.file = file
+	a.line = line
+	arr := strings.Split(item, "|")
+	if len(arr) != 5 {
+		err = a.errorf("", "%q need 5 parts table|relation|field|type|gormTag, got %d", item, len(arr))
+		return
+	}
+	a.Table = arr[0]
+	a.Relation = arr[1]
+	a.Field = arr[2]
+	a.Type = arr[3]
+	a.Gorm = make(map[string]string)
+	for _, kv := range strings.Split(arr[4], ";") {
+		arr2 := strings.SplitN(kv, ":", 2)
+		if len(arr2) != 2 {
+			err = a.errorf("", "%q gorm tag %q need key:value", item, kv)
+			return
+		}
+		a.Gorm[arr2[0]] = arr2[1]
+	}
+	err = a.validate()
+	return
+}
+
+func decodeAssociation(node *yaml.Node, file string) (a Association, err error) {
+	err = node.Decode(&a)
+	if err != nil {
+		err = fmt.Errorf("%s:%d: invalid association: %w", file, node.Line, err)
+		return
+	}
+	a.file = file
+	a.line = node.Line
+	a.lines = make(map[string]int)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !utils.Contains[string](associationKeys, key.Value) {
+			err = fmt.Errorf("%s:%d: invalid association: unknown field %q, support %s", file, key.Line, key.Value, strings.Join(associationKeys, "/"))
+			return
+		}
+		a.lines[key.Value] = key.Line
+	}
+	err = a.validate()
+	return
+}
+
+func (a Association) validate() error {
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"table", a.Table},
+		{"relation", a.Relation},
+		{"field", a.Field},
+		{"type", a.Type},
+	}
+	for _, item := range required {
+		if item.value == "" {
+			return a.errorf(item.key, "%s is required", item.key)
+		}
+	}
+	if !isExported(a.Field) {
+		return a.errorf("field", "field %q must be an exported go field name", a.Field)
+	}
+	if !utils.Contains[string](relationshipTypes, a.Type) {
+		return a.errorf("type", "type %q is not one of %s", a.Type, strings.Join(relationshipTypes, "/"))
+	}
+	for k := range a.Gorm {
+		if !containsFold(gormRelationTagKeys, k) {
+			return a.errorf("gorm", "unknown gorm tag key %q, support %s", k, strings.Join(gormRelationTagKeys, "/"))
+		}
+	}
+	if a.Type == string(field.Many2Many) {
+		if a.JoinTable == "" && !containsFold(mapKeys(a.Gorm), "many2many") {
+			return a.errorf("type", "many_to_many need join-table")
+		}
+	} else if a.JoinTable != "" {
+		return a.errorf("join-table", "join-table is only for many_to_many, got %s", a.Type)
+	}
+	return nil
+}
+
+// checkPreload makes sure every preload field is declared as association of the relation table
+func checkPreload(list []Association) error {
+	for _, a := range list {
+		for _, name := range a.Preload {
+			if _, ok := findAssociation(list, a.Relation, name); !ok {
+				return a.errorf("preload", "preload %q is not an association field of %s", name, a.Relation)
+			}
+		}
+	}
+	return nil
+}
+
+func findAssociation(list []Association, table, fieldName string) (a Association, ok bool) {
+	for _, item := range list {
+		if item.Table == table && item.Field == fieldName {
+			return item, true
+		}
+	}
+	return
+}
+
+// fieldRelate generates the relation model with its preload associations, path prevents circular preload
+func fieldRelate(cfg *CmdGenParams, gormDB *gorm.DB, a Association, path ...string) (opt gen.ModelOpt, err error) {
+	opts, err := modelOpts(cfg, gormDB, a.Relation)
+	if err != nil {
+		return
+	}
+	path = append(path, a.Table+"."+a.Field)
+	for _, name := range a.Preload {
+		nested, _ := findAssociation(*cfg.Association, a.Relation, name)
+		if utils.Contains[string](path, nested.Table+"."+nested.Field) {
+			continue
+		}
+		var nestedOpt gen.ModelOpt
+		nestedOpt, err = fieldRelate(cfg, gormDB, nested, path...)
+		if err != nil {
+			return
+		}
+		opts = append(opts, nestedOpt)
+	}
+	opt = gen.FieldRelate(
+		field.RelationshipType(a.Type),
+		a.Field,
+		newGenerator(cfg, gormDB).GenerateModel(a.Relation, opts...),
+		&field.RelateConfig{
+			GORMTag: a.gormTag(),
+			JSONTag: a.jsonTag(),
+		},
+	)
+	return
+}
+
+// readConfigNode returns the yaml node of keys in file, nil if not found
+func readConfigNode(file string, keys ...string) (node *yaml.Node, err error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var doc yaml.Node
+	err = yaml.Unmarshal(bs, &doc)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", file, err)
+		return
+	}
+	if len(doc.Content) == 0 {
+		return
+	}
+	node = doc.Content[0]
+	for _, key := range keys {
+		node = mappingValue(node, key)
+		if node == nil {
+			return
+		}
+	}
+	return
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isExported(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z'
+}
+
+func containsFold(arr []string, s string) bool {
+	for _, item := range arr {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
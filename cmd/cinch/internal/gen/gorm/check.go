@@ -0,0 +1,211 @@
+package gorm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// genHeader is the first line of files generated by gorm/gen
+const genHeader = "// Code generated by gorm.io/gen. DO NOT EDIT."
+
+// errStale means the committed code is different from the generated code
+var errStale = errors.New("generated code is stale, please run cinch gen gorm")
+
+const (
+	// checkStaleCode is the exit code of --check if the generated code is stale
+	checkStaleCode = 1
+	// checkErrorCode is the exit code of --check if it cannot check, Example: invalid config or connection failure
+	checkErrorCode = 2
+)
+
+// checkModels generates code into a temp dir and compares it with the committed OutPath/ModelPkgName,
+// the unified diff of every changed file is printed, errStale is returned if any file changed
+func checkModels(cfg *CmdGenParams) (err error) {
+	// temp dir must be inside the module, gorm/gen loads the model package to get its import path
+	tmp, err := os.MkdirTemp(".", "cinch-check-")
+	if err != nil {
+		err = fmt.Errorf("create temp dir fail: %w", err)
+		return
+	}
+	defer os.RemoveAll(tmp)
+
+	tmpCfg := *cfg
+	tmpOutPath, err := tempPath(tmp, *cfg.OutPath)
+	if err != nil {
+		return
+	}
+	tmpCfg.OutPath = &tmpOutPath
+	tmpModelPkgName := *cfg.ModelPkgName
+	if strings.Contains(tmpModelPkgName, string(os.PathSeparator)) {
+		tmpModelPkgName, err = tempPath(tmp, tmpModelPkgName)
+		if err != nil {
+			return
+		}
+	}
+	tmpCfg.ModelPkgName = &tmpModelPkgName
+	tmpOutFile := *cfg.OutFile
+	if strings.Contains(tmpOutFile, string(os.PathSeparator)) {
+		tmpOutFile, err = tempPath(tmp, tmpOutFile)
+		if err != nil {
+			return
+		}
+	}
+	tmpCfg.OutFile = &tmpOutFile
+
+	// gorm/gen logs every generated file, only diff is wanted
+	log.SetOutput(io.Discard)
+	err = genModels(&tmpCfg)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		return
+	}
+
+	// import path of model package contains the temp dir
+	normalize := func(content []byte) []byte {
+		return bytes.ReplaceAll(content, []byte("/"+filepath.Base(tmp)+"/"), []byte("/"))
+	}
+	changed := 0
+	if !*cfg.OnlyModel {
+		var n int
+		n, err = diffDir(*cfg.OutPath, tmpOutPath, normalize)
+		if err != nil {
+			return
+		}
+		changed += n
+		if outFile := *cfg.OutFile; strings.Contains(outFile, string(os.PathSeparator)) && filepath.Dir(outFile) != filepath.Clean(*cfg.OutPath) {
+			n, err = diffFile(outFile, tmpOutFile, normalize)
+			if err != nil {
+				return
+			}
+			changed += n
+		}
+	}
+	n, err := diffDir(modelDir(*cfg.OutPath, *cfg.ModelPkgName), modelDir(tmpOutPath, tmpModelPkgName), normalize)
+	if err != nil {
+		return
+	}
+	changed += n
+	if changed > 0 {
+		err = errors.Wrapf(errStale, "%d files differ", changed)
+	}
+	return
+}
+
+// tempPath returns the path in tmp dir which has the same relative path as p
+func tempPath(tmp, p string) (string, error) {
+	if filepath.IsAbs(p) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		p, err = filepath.Rel(wd, p)
+		if err != nil {
+			return "", err
+		}
+	}
+	p = filepath.Clean(p)
+	if strings.HasPrefix(p, "..") {
+		return "", errors.Errorf("path %s must be inside current dir for --check", p)
+	}
+	return filepath.Join(tmp, p), nil
+}
+
+// modelDir is the same as model output path of gorm/gen
+func modelDir(outPath, modelPkgName string) string {
+	if strings.Contains(modelPkgName, string(os.PathSeparator)) {
+		return modelPkgName
+	}
+	return filepath.Join(filepath.Dir(outPath), modelPkgName)
+}
+
+// diffDir compares generated files in dir with files in tmpDir, hand-written files in dir are skipped
+func diffDir(dir, tmpDir string, normalize func([]byte) []byte) (changed int, err error) {
+	names := make(map[string]struct{})
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && isGenerated(filepath.Join(dir, entry.Name())) {
+			names[entry.Name()] = struct{}{}
+		}
+	}
+	entries, err = os.ReadDir(tmpDir)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	err = nil
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names[entry.Name()] = struct{}{}
+		}
+	}
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	for _, name := range list {
+		var n int
+		n, err = diffFile(filepath.Join(dir, name), filepath.Join(tmpDir, name), normalize)
+		if err != nil {
+			return
+		}
+		changed += n
+	}
+	return
+}
+
+// diffFile prints unified diff of file and tmpFile, a missing file is treated as empty
+func diffFile(file, tmpFile string, normalize func([]byte) []byte) (changed int, err error) {
+	committed, err := readOptional(file)
+	if err != nil {
+		return
+	}
+	generated, err := readOptional(tmpFile)
+	if err != nil {
+		return
+	}
+	generated = normalize(generated)
+	if bytes.Equal(committed, generated) {
+		return
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(committed)),
+		B:        difflib.SplitLines(string(generated)),
+		FromFile: "a/" + filepath.ToSlash(file),
+		ToFile:   "b/" + filepath.ToSlash(file),
+		Context:  3,
+	})
+	if err != nil {
+		return
+	}
+	fmt.Print(diff)
+	changed = 1
+	return
+}
+
+func readOptional(file string) (bs []byte, err error) {
+	bs, err = os.ReadFile(file)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+func isGenerated(file string) bool {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(bs, []byte(genHeader)) || bytes.HasPrefix(bs, []byte(cinchHeader))
+}
@@ -0,0 +1,89 @@
+package gorm
+
+import (
+	"go/token"
+
+	"github.com/pkg/errors"
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+)
+
+// ColumnRule customizes a column of tables, table is an exact name, a glob or a regex like tables, in gen.yml:
+//
+//	column:
+//	  - table: user
+//	    column: password
+//	    json: "-"
+//	  - table: user
+//	    column: legacy_flag
+//	    drop: true
+//	  - table: user
+//	    column: usr_nm
+//	    field: UserName
+//	  - table: "*"
+//	    column: email
+//	    tag:
+//	      validate: required,email
+//	      binding: required
+//
+// drop removes the column from model and query, field renames the go field, json overwrites the json tag, tag adds extra struct tags.
+// keys of tag are lower case since viper is case-insensitive.
+type ColumnRule struct {
+	Table  string            `yaml:"table"`
+	Column string            `yaml:"column"`
+	Drop   bool              `yaml:"drop"`
+	Field  string            `yaml:"field"`
+	JSON   string            `yaml:"json"`
+	Tag    map[string]string `yaml:"tag"`
+}
+
+func (c ColumnRule) validate() error {
+	switch {
+	case c.Table == "" || c.Column == "":
+		return errors.Errorf("invalid column %+v: table and column are required", c)
+	case !c.Drop && c.Field == "" && c.JSON == "" && len(c.Tag) == 0:
+		return errors.Errorf("invalid column %+v: one of drop/field/json/tag is required", c)
+	case c.Drop && (c.Field != "" || c.JSON != "" || len(c.Tag) > 0):
+		return errors.Errorf("invalid column %+v: drop cannot be used with field/json/tag", c)
+	case c.Field != "" && (!token.IsIdentifier(c.Field) || !token.IsExported(c.Field)):
+		return errors.Errorf("invalid column %+v: field %s is not an exported go identifier", c, c.Field)
+	}
+	for k := range c.Tag {
+		switch k {
+		case field.TagKeyJson:
+			return errors.Errorf("invalid column %+v: use json instead of tag json", c)
+		case field.TagKeyGorm:
+			return errors.Errorf("invalid column %+v: gorm tag is generated from database", c)
+		}
+	}
+	return tablePattern(c.Table).validate()
+}
+
+// columnOpts returns the options of column rules which match table
+func columnOpts(cfg *CmdGenParams, table string) (opts []gen.ModelOpt) {
+	for _, item := range *cfg.Column {
+		if _, ok := matchTable([]string{item.Table}, table); !ok {
+			continue
+		}
+		if item.Drop {
+			opts = append(opts, gen.FieldIgnore(item.Column))
+			continue
+		}
+		if item.Field != "" {
+			opts = append(opts, gen.FieldRename(item.Column, item.Field))
+		}
+		if item.JSON != "" {
+			opts = append(opts, gen.FieldJSONTag(item.Column, item.JSON))
+		}
+		if len(item.Tag) > 0 {
+			tags := item.Tag
+			opts = append(opts, gen.FieldTag(item.Column, func(tag field.Tag) field.Tag {
+				for k, v := range tags {
+					tag.Set(k, v)
+				}
+				return tag
+			}))
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package gorm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+// DataType maps a database column type to go type, it works for all tables, a table or a column:
+//
+//	data-type:
+//	  - db-type: json
+//	    go-type: datatypes.JSON
+//	    import: gorm.io/datatypes
+//	  - db-type: datetime
+//	    go-type: time.Time
+//	  - table: order
+//	    db-type: decimal
+//	    go-type: money.Money
+//	    import: example.com/pkg/money
+//	  - table: user
+//	    column: birthday
+//	    go-type: carbon.Date
+//	    import: github.com/golang-module/carbon/v2
+//	  - table: user
+//	    column: settings
+//	    go-type: biz.UserSettings
+//	    import: example.com/app/internal/biz
+//	    serializer: json
+//	  - table: post
+//	    column: tags
+//	    go-type: "[]string"
+//	    serializer: json
+//
+// column mapping is prior to table mapping, table mapping is prior to global mapping.
+// serializer is json/gob/unixtime, consult[https://gorm.io/docs/serializer.html], it needs table.
+type DataType struct {
+	Table      string `yaml:"table"`
+	Column     string `yaml:"column"`
+	DBType     string `yaml:"dbType" mapstructure:"db-type"`
+	GoType     string `yaml:"goType" mapstructure:"go-type"`
+	Import     string `yaml:"import"`
+	Serializer string `yaml:"serializer"`
+}
+
+var serializers = []string{"json", "gob", "unixtime"}
+
+// defaultDataTypes are used unless gen.yml maps the same db type globally
+var defaultDataTypes = []DataType{
+	{DBType: "decimal", GoType: "decimal.Decimal", Import: "github.com/shopspring/decimal"},
+	{DBType: "datetime", GoType: "carbon.DateTime", Import: "github.com/golang-module/carbon/v2"},
+	{DBType: "date", GoType: "carbon.Date", Import: "github.com/golang-module/carbon/v2"},
+}
+
+func (d DataType) validate() error {
+	switch {
+	case d.GoType == "":
+		return errors.Errorf("invalid data type %+v: go-type is required", d)
+	case d.Column == "" && d.DBType == "":
+		return errors.Errorf("invalid data type %+v: db-type or column is required", d)
+	case d.Column != "" && d.Table == "":
+		return errors.Errorf("invalid data type %+v: table is required by column", d)
+	case d.Column != "" && d.DBType != "":
+		return errors.Errorf("invalid data type %+v: column and db-type cannot be used together", d)
+	case d.Serializer != "" && d.Table == "":
+		return errors.Errorf("invalid data type %+v: table is required by serializer", d)
+	case d.Serializer != "" && !utils.Contains[string](serializers, d.Serializer):
+		return errors.Errorf("invalid data type %+v: serializer %q is not one of %s", d, d.Serializer, strings.Join(serializers, "/"))
+	}
+	return nil
+}
+
+// globalDataTypes returns the default data types overwritten by global data types in gen.yml
+func globalDataTypes(cfg *CmdGenParams) (list []DataType) {
+	list = make([]DataType, 0, len(defaultDataTypes)+len(*cfg.DataType))
+	for _, item := range defaultDataTypes {
+		overwrite := false
+		for _, d := range *cfg.DataType {
+			if d.Table == "" && strings.EqualFold(d.DBType, item.DBType) {
+				overwrite = true
+				break
+			}
+		}
+		if !overwrite {
+			list = append(list, item)
+		}
+	}
+	for _, d := range *cfg.DataType {
+		if d.Table == "" {
+			list = append(list, d)
+		}
+	}
+	return
+}
+
+// dataTypeMap is used by WithDataTypeMap, the key is DatabaseTypeName of column,
+// its case differs between drivers, so both lower and upper case are set
+func dataTypeMap(cfg *CmdGenParams) map[string]func(gorm.ColumnType) (dataType string) {
+	m := make(map[string]func(gorm.ColumnType) (dataType string))
+	for _, item := range globalDataTypes(cfg) {
+		goType := item.GoType
+		fc := func(gorm.ColumnType) (dataType string) {
+			return goType
+		}
+		m[item.DBType] = fc
+		m[strings.ToLower(item.DBType)] = fc
+		m[strings.ToUpper(item.DBType)] = fc
+	}
+	return m
+}
+
+// dataTypeImports returns import paths of all data types
+func dataTypeImports(cfg *CmdGenParams) (paths []string) {
+	for _, item := range append(globalDataTypes(cfg), *cfg.DataType...) {
+		if item.Import != "" && !utils.Contains[string](paths, item.Import) {
+			paths = append(paths, item.Import)
+		}
+	}
+	return
+}
+
+// dataTypeOpts returns field type options of table mapping and column mapping
+func dataTypeOpts(cfg *CmdGenParams, gormDB *gorm.DB, table string) (opts []gen.ModelOpt, err error) {
+	tableTypes := make([]DataType, 0)
+	columnTypes := make([]DataType, 0)
+	for _, item := range *cfg.DataType {
+		if item.Table != table {
+			continue
+		}
+		if item.Column != "" {
+			columnTypes = append(columnTypes, item)
+		} else {
+			tableTypes = append(tableTypes, item)
+		}
+	}
+	if len(tableTypes) > 0 {
+		var cols []gorm.ColumnType
+		cols, err = gormDB.Migrator().ColumnTypes(table)
+		if err != nil {
+			err = fmt.Errorf("GORM migrator get columns of %s fail: %w", table, err)
+			return
+		}
+		for _, col := range cols {
+			for _, item := range tableTypes {
+				if strings.EqualFold(col.DatabaseTypeName(), item.DBType) {
+					opts = append(opts, item.fieldOpts(col.Name())...)
+				}
+			}
+		}
+	}
+	// column mapping is applied last to overwrite table mapping
+	for _, item := range columnTypes {
+		opts = append(opts, item.fieldOpts(item.Column)...)
+	}
+	return
+}
+
+// fieldOpts returns field type option of column, and gorm serializer tag if any
+func (d DataType) fieldOpts(column string) (opts []gen.ModelOpt) {
+	opts = append(opts, gen.FieldType(column, d.GoType))
+	if d.Serializer != "" {
+		serializer := d.Serializer
+		opts = append(opts, gen.FieldGORMTag(column, func(tag field.GormTag) field.GormTag {
+			tag.Set("serializer", serializer)
+			return tag
+		}))
+	}
+	return
+}
@@ -0,0 +1,429 @@
+package gorm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gen"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// cinchHeader is the first line of files generated by cinch
+const cinchHeader = "// Code generated by cinch. DO NOT EDIT."
+
+var (
+	// enumCommentRegexp matches column comment marker, Example: status enum: 1=active, 2=locked
+	enumCommentRegexp = regexp.MustCompile(`enum:\s*([^\s;,=]+\s*=\s*[^\s;,]+(?:\s*,\s*[^\s;,=]+\s*=\s*[^\s;,]+)*)`)
+	// enumColumnRegexp matches mysql column type, Example: enum('active','locked')
+	enumColumnRegexp = regexp.MustCompile(`(?i)^enum\((.*)\)$`)
+	identRegexp      = regexp.MustCompile(`[^0-9A-Za-z]+`)
+)
+
+// Enum is a go type generated from enum column
+type Enum struct {
+	Table  string
+	Column string
+	Type   string
+	Base   string
+	Values []EnumValue
+}
+
+// EnumValue is a constant of Enum
+type EnumValue struct {
+	// Ident is the constant name suffix
+	Ident string
+	// Name is returned by String()
+	Name string
+	// Value is the stored value
+	Value string
+}
+
+// IsString reports whether enum is stored as string
+func (e Enum) IsString() bool {
+	return e.Base == "string"
+}
+
+// Unsigned reports whether enum base type is unsigned integer
+func (e Enum) Unsigned() bool {
+	return strings.HasPrefix(e.Base, "uint")
+}
+
+// GenType is the field type in query package
+func (e Enum) GenType() string {
+	return utils.CamelCase(e.Base)
+}
+
+// Literal returns v as go literal
+func (e Enum) Literal(v EnumValue) string {
+	if e.IsString() {
+		return strconv.Quote(v.Value)
+	}
+	return v.Value
+}
+
+// tableEnums detects enum columns of table, by mysql ENUM type or column comment marker
+func tableEnums(cfg *CmdGenParams, gormDB *gorm.DB, table string) (list []Enum, err error) {
+	if !*cfg.FieldWithEnum {
+		return
+	}
+	cols, err := gormDB.Migrator().ColumnTypes(table)
+	if err != nil {
+		err = fmt.Errorf("GORM migrator get columns of %s fail: %w", table, err)
+		return
+	}
+	ns := schema.NamingStrategy{}
+	name := modelName(cfg, gormDB, table)
+	for _, col := range cols {
+		var values []EnumValue
+		base := "string"
+		colType, _ := col.ColumnType()
+		comment, _ := col.Comment()
+		if m := enumCommentRegexp.FindStringSubmatch(comment); m != nil {
+			base = enumBaseType(cfg, col)
+			values, err = parseEnumComment(m[1], base)
+		} else if m := enumColumnRegexp.FindStringSubmatch(colType); m != nil {
+			values, err = parseEnumColumn(m[1])
+		} else {
+			continue
+		}
+		if err != nil {
+			err = errors.Errorf("invalid enum of %s.%s: %v", table, col.Name(), err)
+			return
+		}
+		list = append(list, Enum{
+			Table:  table,
+			Column: col.Name(),
+			Type:   name + ns.SchemaName(col.Name()),
+			Base:   base,
+			Values: values,
+		})
+	}
+	return
+}
+
+// enumBaseType is the go type of integer column, others are string
+func enumBaseType(cfg *CmdGenParams, col gorm.ColumnType) string {
+	colType, _ := col.ColumnType()
+	prefix := ""
+	if *cfg.FieldSignable && strings.Contains(strings.ToLower(colType), "unsigned") {
+		prefix = "u"
+	}
+	switch strings.ToLower(col.DatabaseTypeName()) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "year":
+		return prefix + "int32"
+	case "bigint":
+		return prefix + "int64"
+	}
+	return "string"
+}
+
+// parseEnumComment parses "1=active,2=locked"
+func parseEnumComment(s, base string) (values []EnumValue, err error) {
+	for _, item := range strings.Split(s, ",") {
+		arr := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		for i := range arr {
+			arr[i] = strings.TrimSpace(arr[i])
+		}
+		if len(arr) != 2 || arr[0] == "" || arr[1] == "" {
+			err = errors.Errorf("%q need value=name", item)
+			return
+		}
+		if base != "string" {
+			if _, e := strconv.ParseInt(arr[0], 10, 64); e != nil {
+				err = errors.Errorf("%q is not integer", arr[0])
+				return
+			}
+		}
+		values = append(values, EnumValue{Ident: enumIdent(arr[1]), Name: arr[1], Value: arr[0]})
+	}
+	return checkEnumValues(values)
+}
+
+// parseEnumColumn parses "'active','locked'"
+func parseEnumColumn(s string) (values []EnumValue, err error) {
+	for _, item := range splitQuoted(s) {
+		values = append(values, EnumValue{Ident: enumIdent(item), Name: item, Value: item})
+	}
+	return checkEnumValues(values)
+}
+
+// splitQuoted splits mysql quoted list, two single quotes is an escaped quote
+func splitQuoted(s string) (list []string) {
+	var (
+		buf    strings.Builder
+		quoted bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(s) && s[i+1] == '\'':
+			buf.WriteByte(c)
+			i++
+		case c == '\'':
+			quoted = !quoted
+			if !quoted {
+				list = append(list, buf.String())
+				buf.Reset()
+			}
+		case quoted:
+			buf.WriteByte(c)
+		}
+	}
+	return
+}
+
+func checkEnumValues(values []EnumValue) ([]EnumValue, error) {
+	if len(values) == 0 {
+		return nil, errors.New("no value")
+	}
+	idents := make([]string, 0, len(values))
+	for _, v := range values {
+		if v.Ident == "" {
+			return nil, errors.Errorf("cannot name constant of %q", v.Name)
+		}
+		if utils.Contains[string](idents, v.Ident) {
+			return nil, errors.Errorf("duplicate constant %s", v.Ident)
+		}
+		idents = append(idents, v.Ident)
+	}
+	return values, nil
+}
+
+// enumIdent converts name to go identifier suffix, Example: in-progress => InProgress
+func enumIdent(name string) string {
+	ident := utils.CamelCase(identRegexp.ReplaceAllString(name, "_"))
+	if ident != "" && ident[0] >= '0' && ident[0] <= '9' {
+		ident = "V" + ident
+	}
+	return ident
+}
+
+// enumOpts returns options which use enum types as field type
+func enumOpts(cfg *CmdGenParams, gormDB *gorm.DB, table string) (opts []gen.ModelOpt, err error) {
+	list, err := tableEnums(cfg, gormDB, table)
+	if err != nil {
+		return
+	}
+	for _, item := range list {
+		opts = append(opts, gen.FieldType(item.Column, item.Type), gen.FieldGenType(item.Column, item.GenType()))
+	}
+	return
+}
+
+// genEnums writes enum types of tables into model package, one file per table
+func genEnums(cfg *CmdGenParams, gormDB *gorm.DB, tables []string) (err error) {
+	dir := modelDir(*cfg.OutPath, *cfg.ModelPkgName)
+	for _, table := range tables {
+		var list []Enum
+		list, err = tableEnums(cfg, gormDB, table)
+		if err != nil {
+			return
+		}
+		if len(list) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		needStrconv := false
+		for _, item := range list {
+			needStrconv = needStrconv || !item.IsString()
+		}
+		err = enumTemplate.Execute(&buf, map[string]interface{}{
+			"Header":      cinchHeader,
+			"Package":     filepath.Base(dir),
+			"Enums":       list,
+			"NeedStrconv": needStrconv,
+		})
+		if err != nil {
+			return
+		}
+		var content []byte
+		content, err = format.Source(buf.Bytes())
+		if err != nil {
+			err = fmt.Errorf("format enum of %s fail: %w", table, err)
+			return
+		}
+		err = os.WriteFile(filepath.Join(dir, fileName(cfg, table)+"_enum.gen.go"), content, 0640)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+var enumTemplate = template.Must(template.New("enum").Parse(`{{.Header}}
+
+package {{.Package}}
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+{{- if .NeedStrconv}}
+	"strconv"
+{{- end}}
+)
+{{range $e := .Enums}}
+// {{$e.Type}} is the enum of {{$e.Table}}.{{$e.Column}}
+type {{$e.Type}} {{$e.Base}}
+
+const (
+{{- range $e.Values}}
+	{{$e.Type}}{{.Ident}} {{$e.Type}} = {{$e.Literal .}}
+{{- end}}
+)
+
+// {{$e.Type}}Values returns all values of {{$e.Type}}
+func {{$e.Type}}Values() []{{$e.Type}} {
+	return []{{$e.Type}}{
+{{- range $e.Values}}
+		{{$e.Type}}{{.Ident}},
+{{- end}}
+	}
+}
+
+// IsValid reports whether e is a declared value
+func (e {{$e.Type}}) IsValid() bool {
+	switch e {
+	case {{range $i, $v := $e.Values}}{{if $i}}, {{end}}{{$e.Type}}{{$v.Ident}}{{end}}:
+		return true
+	}
+	return false
+}
+
+// String returns the name of e
+func (e {{$e.Type}}) String() string {
+	switch e {
+{{- range $e.Values}}
+	case {{$e.Type}}{{.Ident}}:
+		return {{printf "%q" .Name}}
+{{- end}}
+	}
+	return fmt.Sprintf("{{$e.Type}}(%v)", {{$e.Base}}(e))
+}
+
+// Parse{{$e.Type}} returns the value of name or stored value
+func Parse{{$e.Type}}(s string) ({{$e.Type}}, error) {
+	for _, v := range {{$e.Type}}Values() {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+{{- if $e.IsString}}
+	v := {{$e.Type}}(s)
+{{- else if $e.Unsigned}}
+	i, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid {{$e.Type}} %q", s)
+	}
+	v := {{$e.Type}}(i)
+{{- else}}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid {{$e.Type}} %q", s)
+	}
+	v := {{$e.Type}}(i)
+{{- end}}
+	if !v.IsValid() {
+		return v, fmt.Errorf("invalid {{$e.Type}} %q", s)
+	}
+	return v, nil
+}
+
+// MarshalJSON implements json.Marshaler, the name is used, an undeclared value is marshaled as the stored value
+func (e {{$e.Type}}) MarshalJSON() ([]byte, error) {
+	if !e.IsValid() {
+		return json.Marshal({{$e.Base}}(e))
+	}
+	return json.Marshal(e.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, both name and stored value are accepted{{if not $e.IsString}},
+// a number is the stored value which is kept even if undeclared as Scan does{{end}}
+func (e *{{$e.Type}}) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+{{- if $e.IsString}}
+		return err
+{{- else}}
+		var i {{$e.Base}}
+		if json.Unmarshal(data, &i) != nil {
+			return err
+		}
+		*e = {{$e.Type}}(i)
+		return nil
+{{- end}}
+	}
+	v, err := Parse{{$e.Type}}(s)
+	if err != nil {
+		return err
+	}
+	*e = v
+	return nil
+}
+
+// Scan implements sql.Scanner
+func (e *{{$e.Type}}) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*e = {{if $e.IsString}}""{{else}}0{{end}}
+		return nil
+{{- if not $e.IsString}}
+	case int64:
+		*e = {{$e.Type}}(v)
+		return nil
+	case uint64:
+		*e = {{$e.Type}}(v)
+		return nil
+{{- end}}
+	case []byte:
+		return e.scanString(string(v))
+	case string:
+		return e.scanString(v)
+	}
+	return fmt.Errorf("cannot scan %T into {{$e.Type}}", src)
+}
+
+func (e *{{$e.Type}}) scanString(s string) error {
+{{- if $e.IsString}}
+	*e = {{$e.Type}}(s)
+{{- else if $e.Unsigned}}
+	i, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*e = {{$e.Type}}(i)
+{{- else}}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*e = {{$e.Type}}(i)
+{{- end}}
+	return nil
+}
+
+// Value implements driver.Valuer
+func (e {{$e.Type}}) Value() (driver.Value, error) {
+{{- if $e.IsString}}
+	return string(e), nil
+{{- else if $e.Unsigned}}
+	// driver.Value has no uint64, a value above MaxInt64 is sent as string
+	if uint64(e) > 1<<63-1 {
+		return strconv.FormatUint(uint64(e), 10), nil
+	}
+	return int64(e), nil
+{{- else}}
+	return int64(e), nil
+{{- end}}
+}
+{{end}}`))
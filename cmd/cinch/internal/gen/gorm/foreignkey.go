@@ -0,0 +1,252 @@
+package gorm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/jinzhu/inflection"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// foreignKeySQL lists foreign keys of the current database, sqlite uses PRAGMA foreign_key_list per table
+var foreignKeySQL = map[string]string{
+	"mysql": `SELECT TABLE_NAME AS table_name, COLUMN_NAME AS column_name,
+       REFERENCED_TABLE_NAME AS ref_table, REFERENCED_COLUMN_NAME AS ref_column
+FROM information_schema.KEY_COLUMN_USAGE
+WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL
+ORDER BY TABLE_NAME, ORDINAL_POSITION`,
+	"postgres": `SELECT tc.table_name AS table_name, kcu.column_name AS column_name,
+       ccu.table_name AS ref_table, ccu.column_name AS ref_column
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu
+  ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+JOIN information_schema.constraint_column_usage ccu
+  ON ccu.constraint_name = tc.constraint_name AND ccu.table_schema = tc.table_schema
+WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = CURRENT_SCHEMA()
+ORDER BY tc.table_name, kcu.ordinal_position`,
+	"sqlserver": `SELECT OBJECT_NAME(fkc.parent_object_id) AS table_name, pc.name AS column_name,
+       OBJECT_NAME(fkc.referenced_object_id) AS ref_table, rc.name AS ref_column
+FROM sys.foreign_key_columns fkc
+JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+WHERE OBJECT_SCHEMA_NAME(fkc.parent_object_id) = SCHEMA_NAME()
+ORDER BY table_name, fkc.constraint_column_id`,
+}
+
+// joinTableExtraColumns are the columns a join table may have besides its two foreign keys
+var joinTableExtraColumns = []string{"id", "created_at", "updated_at"}
+
+// foreignKey is a single column foreign key
+type foreignKey struct {
+	Table     string `gorm:"column:table_name"`
+	Column    string `gorm:"column:column_name"`
+	RefTable  string `gorm:"column:ref_table"`
+	RefColumn string `gorm:"column:ref_column"`
+}
+
+// listForeignKeys returns foreign keys between tables
+func listForeignKeys(gormDB *gorm.DB, tables []string) (list []foreignKey, err error) {
+	name := gormDB.Dialector.Name()
+	var all []foreignKey
+	if name == "sqlite" {
+		for _, table := range tables {
+			var rows []struct {
+				Table string  `gorm:"column:table"`
+				From  string  `gorm:"column:from"`
+				To    *string `gorm:"column:to"`
+			}
+			err = gormDB.Raw(fmt.Sprintf("PRAGMA foreign_key_list(%q)", table)).Scan(&rows).Error
+			if err != nil {
+				err = fmt.Errorf("get foreign keys of %s fail: %w", table, err)
+				return
+			}
+			for _, row := range rows {
+				// the primary key is referenced if to is omitted
+				refColumn := "id"
+				if row.To != nil && *row.To != "" {
+					refColumn = *row.To
+				}
+				all = append(all, foreignKey{Table: table, Column: row.From, RefTable: row.Table, RefColumn: refColumn})
+			}
+		}
+	} else {
+		query, ok := foreignKeySQL[name]
+		if !ok {
+			err = errors.Errorf("association from foreign key is not supported by %s (support mysql || postgres || sqlite || sqlserver for now)", name)
+			return
+		}
+		err = gormDB.Raw(query).Scan(&all).Error
+		if err != nil {
+			err = fmt.Errorf("get foreign keys fail: %w", err)
+			return
+		}
+	}
+	for _, item := range all {
+		if utils.Contains[string](tables, item.Table) && utils.Contains[string](tables, item.RefTable) {
+			list = append(list, item)
+		}
+	}
+	return
+}
+
+// inferAssociations infers associations from foreign keys between tables:
+// a foreign key is a belongs_to of its table and a has_many of the referenced table,
+// a table which only has two foreign keys and joinTableExtraColumns is the join table of a many_to_many pair.
+// the associations of gen.yml are prior to inferred ones
+func inferAssociations(cfg *CmdGenParams, gormDB *gorm.DB, tables []string) (list []Association, err error) {
+	fks, err := listForeignKeys(gormDB, tables)
+	if err != nil {
+		return
+	}
+	byTable := make(map[string][]foreignKey)
+	for _, fk := range fks {
+		byTable[fk.Table] = append(byTable[fk.Table], fk)
+	}
+
+	fields := make(map[string][]string)
+	for _, a := range *cfg.Association {
+		fields[a.Table] = append(fields[a.Table], a.Field)
+	}
+	add := func(a Association) {
+		for _, item := range *cfg.Association {
+			if item.Table == a.Table && item.Relation == a.Relation {
+				return
+			}
+		}
+		if utils.Contains[string](fields[a.Table], a.Field) {
+			return
+		}
+		fields[a.Table] = append(fields[a.Table], a.Field)
+		// the default json tag is the relation, it repeats if table has several associations of relation
+		a.JSON = utils.CamelCaseLowerFirst(a.Field)
+		list = append(list, a)
+	}
+
+	ns := schema.NamingStrategy{}
+	for _, table := range tables {
+		items := byTable[table]
+		if len(items) == 0 {
+			continue
+		}
+		var join bool
+		join, err = isJoinTable(gormDB, table, items)
+		if err != nil {
+			return
+		}
+		if join {
+			for i, fk := range items {
+				other := items[1-i]
+				add(Association{
+					Table:     fk.RefTable,
+					Relation:  other.RefTable,
+					Field:     inflection.Plural(ns.SchemaName(other.RefTable)),
+					Type:      string(field.Many2Many),
+					JoinTable: table,
+					Gorm: map[string]string{
+						"joinForeignKey": ns.SchemaName(fk.Column),
+						"joinReferences": ns.SchemaName(other.Column),
+					},
+				})
+			}
+			continue
+		}
+		for _, fk := range items {
+			gormTag := map[string]string{
+				"foreignKey": ns.SchemaName(fk.Column),
+				"references": ns.SchemaName(fk.RefColumn),
+			}
+			// author_id => Author/Articles, created_by => CreatedByAuthor/CreatedByArticles
+			prefix := strings.TrimSuffix(fk.Column, "_id")
+			name := ns.SchemaName(prefix)
+			if prefix == fk.Column {
+				name = ns.SchemaName(fk.Column + "_" + fk.RefTable)
+			}
+			// a model cannot contain itself, self reference is has_many only
+			if fk.RefTable != table {
+				add(Association{
+					Table:    table,
+					Relation: fk.RefTable,
+					Field:    name,
+					Type:     string(field.BelongsTo),
+					Gorm:     gormTag,
+				})
+			}
+			name = inflection.Plural(ns.SchemaName(table))
+			if prefix != fk.RefTable {
+				name = ns.SchemaName(prefix) + name
+			}
+			add(Association{
+				Table:    fk.RefTable,
+				Relation: table,
+				Field:    name,
+				Type:     string(field.HasMany),
+				Gorm:     gormTag,
+			})
+		}
+	}
+	return
+}
+
+// isJoinTable reports whether table only has the two foreign keys of different tables and joinTableExtraColumns
+func isJoinTable(gormDB *gorm.DB, table string, fks []foreignKey) (ok bool, err error) {
+	if len(fks) != 2 || fks[0].RefTable == fks[1].RefTable {
+		return
+	}
+	cols, err := gormDB.Migrator().ColumnTypes(table)
+	if err != nil {
+		err = fmt.Errorf("GORM migrator get columns of %s fail: %w", table, err)
+		return
+	}
+	for _, col := range cols {
+		name := col.Name()
+		if name != fks[0].Column && name != fks[1].Column && !utils.Contains[string](joinTableExtraColumns, name) {
+			return
+		}
+	}
+	return true, nil
+}
+
+// withForeignKeyAssociations appends associations inferred from foreign keys to cfg and prints them,
+// tables are the ones selected by tables and exclude
+func withForeignKeyAssociations(cfg *CmdGenParams, gormDB *gorm.DB) (err error) {
+	if !*cfg.AssociationFromFK {
+		return
+	}
+	selections, err := selectTables(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	tables := make([]string, 0, len(selections))
+	for _, item := range selections {
+		if item.included {
+			tables = append(tables, item.table)
+		}
+	}
+	list, err := inferAssociations(cfg, gormDB, tables)
+	if err != nil || len(list) == 0 {
+		return
+	}
+	associations := append(append(make([]Association, 0, len(*cfg.Association)+len(list)), *cfg.Association...), list...)
+	cfg.Association = &associations
+	printAssociations(list)
+	return
+}
+
+// printAssociations prints list as the association of gen.yml, so that it can be pinned and customized
+func printAssociations(list []Association) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	_ = enc.Encode(map[string]interface{}{"association": list})
+	_ = enc.Close()
+	fmt.Printf("inferred %d associations from foreign keys, pin them in gen.yml to customize:\n", len(list))
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+}
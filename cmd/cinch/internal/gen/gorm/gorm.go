@@ -3,6 +3,7 @@ package gorm
 import (
 	"fmt"
 	"github.com/fatih/color"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/migrate"
 	"github.com/go-cinch/common/plugins/gorm/filter"
 	"github.com/go-cinch/common/utils"
 	"github.com/pkg/errors"
@@ -14,9 +15,7 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gen"
-	"gorm.io/gen/field"
 	"gorm.io/gorm"
-	"log"
 	"os"
 	"strings"
 )
@@ -29,22 +28,32 @@ var CmdGorm = &cobra.Command{
 }
 
 var (
-	config             string
-	dsn                string
-	db                 string
-	tables             string
-	exclude            string
-	association        string
-	outPath            string
-	outFile            string
-	modelPkgName       string
-	fieldWithStringTag string
-	onlyModel          bool
-	withUnitTest       bool
-	fieldNullable      bool
-	fieldWithIndexTag  bool
-	fieldWithTypeTag   bool
-	fieldSignable      bool
+	config                      string
+	dsn                         string
+	db                          string
+	tables                      string
+	exclude                     string
+	association                 string
+	associationFromFK           bool
+	withView                    bool
+	outPath                     string
+	outFile                     string
+	modelPkgName                string
+	fieldWithStringTag          string
+	fieldWithInt64String        bool
+	fieldWithInt64StringExclude string
+	onlyModel                   bool
+	withUnitTest                bool
+	fieldNullable               bool
+	fieldWithIndexTag           bool
+	fieldWithTypeTag            bool
+	fieldSignable               bool
+	fromMigration               bool
+	migrateEnv                  string
+	check                       bool
+	fieldWithEnum               bool
+	source                      string
+	list                        bool
 )
 
 // argParse is parser for cmd
@@ -53,34 +62,55 @@ func init() {
 	dsn = ""
 	db = "mysql"
 	tables = ""
-	exclude = "schema_migrations"
+	// tables of migrator are not models, the migration records, checksums and the sqlite lock
+	exclude = strings.Join(migrate.Environment{}.Tables(), ",")
 	association = ""
+	associationFromFK = false
+	withView = false
 	outPath = "internal/data/query"
 	outFile = ""
 	modelPkgName = "internal/data/model"
 	fieldWithStringTag = ""
+	fieldWithInt64String = false
+	fieldWithInt64StringExclude = ""
 	onlyModel = false
 	withUnitTest = false
 	fieldNullable = false
 	fieldWithIndexTag = false
 	fieldWithTypeTag = false
 	fieldSignable = true
+	fromMigration = false
+	migrateEnv = migrate.DefaultEnv
+	check = false
+	fieldWithEnum = false
+	source = ""
+	list = false
 	CmdGorm.PersistentFlags().StringVarP(&config, "config", "c", config, "is path for gen.yml")
 	CmdGorm.PersistentFlags().StringVarP(&dsn, "dsn", "", dsn, "consult[https://gorm.io/docs/connecting_to_the_database.html]")
 	CmdGorm.PersistentFlags().StringVarP(&db, "db", "", db, "input mysql|postgres|sqlite|sqlserver|clickhouse. consult[https://gorm.io/docs/connecting_to_the_database.html]")
-	CmdGorm.PersistentFlags().StringVarP(&tables, "tables", "t", tables, "enter the required data table or leave it blank")
-	CmdGorm.PersistentFlags().StringVarP(&exclude, "exclude", "e", exclude, "enter the exclude data table or leave it blank")
-	CmdGorm.PersistentFlags().StringVarP(&association, "association", "a", association, "enter the association data table or leave it blank, index1: table name; index2: relation table name; index3: field name; index4: relation type(has_one/has_many/belongs_to/many_to_many); index5: gorm tag. Example: -a \"user|role|Role|has_one|foreignKey:RoleID\"")
+	CmdGorm.PersistentFlags().StringVarP(&tables, "tables", "t", tables, "enter the required data table or leave it blank, glob and regex enclosed by slashes are supported. Example: -t \"user,sys_*,/^tmp_\\d+$/\"")
+	CmdGorm.PersistentFlags().StringVarP(&exclude, "exclude", "e", exclude, "enter the exclude data table or leave it blank, glob and regex enclosed by slashes are supported. Example: -e \"schema_migrations,*_bak\"")
+	CmdGorm.PersistentFlags().StringVarP(&association, "association", "a", association, "enter the association data table or leave it blank, index1: table name; index2: relation table name; index3: field name; index4: relation type(has_one/has_many/belongs_to/many_to_many); index5: gorm tag, split by ';'. Example: -a \"user|role|Role|has_one|foreignKey:RoleID\", structured association is supported in gen.yml")
+	CmdGorm.PersistentFlags().BoolVarP(&associationFromFK, "associationFromFK", "", associationFromFK, "infer belongs_to/has_many/many_to_many associations from foreign keys of database, the association flag or gen.yml is prior to them")
+	CmdGorm.PersistentFlags().BoolVarP(&withView, "withView", "", withView, "generate views and postgres materialized views of search path too, their query has no create/update/delete method")
 	CmdGorm.PersistentFlags().StringVarP(&outPath, "outPath", "p", outPath, "specify a directory for output")
 	CmdGorm.PersistentFlags().StringVarP(&outFile, "outFile", "", outFile, "query code file name, default: gen.go")
 	CmdGorm.PersistentFlags().StringVarP(&modelPkgName, "modelPkgName", "m", modelPkgName, "generated model code's package name")
 	CmdGorm.PersistentFlags().StringVarP(&fieldWithStringTag, "fieldWithStringTag", "s", fieldWithStringTag, "field need add ,string json tag, index1: table name; index i: field name Example: -s \"user|role_id,user_group|lock_expire|wrong\"")
+	CmdGorm.PersistentFlags().BoolVarP(&fieldWithInt64String, "fieldWithInt64String", "", fieldWithInt64String, "add ,string json tag to all BIGINT columns, javascript loses precision of them")
+	CmdGorm.PersistentFlags().StringVarP(&fieldWithInt64StringExclude, "fieldWithInt64StringExclude", "", fieldWithInt64StringExclude, "BIGINT columns without ,string json tag, item is column or table|column. Example: \"view_count,user|score\"")
 	CmdGorm.PersistentFlags().BoolVarP(&onlyModel, "onlyModel", "o", onlyModel, "only generate models (without query file)")
 	CmdGorm.PersistentFlags().BoolVarP(&withUnitTest, "withUnitTest", "", withUnitTest, "generate unit test for query code")
 	CmdGorm.PersistentFlags().BoolVarP(&fieldNullable, "fieldNullable", "", fieldNullable, "generate with pointer when field is nullable")
 	CmdGorm.PersistentFlags().BoolVarP(&fieldWithIndexTag, "fieldWithIndexTag", "", fieldWithIndexTag, "generate field with gorm index tag")
 	CmdGorm.PersistentFlags().BoolVarP(&fieldWithTypeTag, "fieldWithTypeTag", "", fieldWithTypeTag, "generate field with gorm column type tag")
 	CmdGorm.PersistentFlags().BoolVarP(&fieldSignable, "fieldSignable", "", fieldSignable, "detect integer field's unsigned type, adjust generated data type")
+	CmdGorm.PersistentFlags().BoolVarP(&fromMigration, "fromMigration", "", fromMigration, "apply migration files to a throwaway in-process database and generate from it, no dsn required")
+	CmdGorm.PersistentFlags().StringVarP(&migrateEnv, "migrateEnv", "", migrateEnv, "migrate environment in gen.yml which provides dialect and migration dir, used with --fromMigration")
+	CmdGorm.PersistentFlags().BoolVarP(&fieldWithEnum, "fieldWithEnum", "", fieldWithEnum, "generate go enum type for mysql ENUM column or column comment has marker, Example: 'status enum: 1=active,2=locked'")
+	CmdGorm.PersistentFlags().StringVarP(&source, "source", "", source, "enter the source names of gen.yml sources or leave it blank to generate all. Example: --source \"primary,analytics\"")
+	CmdGorm.PersistentFlags().BoolVarP(&list, "list", "", list, "print the tables would be generated and why they are included or excluded, without generating")
+	CmdGorm.PersistentFlags().BoolVarP(&check, "check", "", check, "generate into a temp dir and compare with outPath/modelPkgName, print diff and exit 1 if stale, exit 2 if it cannot check")
 }
 
 // DBType database type
@@ -96,35 +126,52 @@ const (
 )
 
 type CmdGenParams struct {
-	DSN                *string   `yaml:"dsn"`
-	DB                 *string   `yaml:"db"`
-	Tables             *[]string `yaml:"tables"`
-	Exclude            *[]string `yaml:"exclude"`
-	Association        *[]string `yaml:"association"`
-	OutPath            *string   `yaml:"outPath" mapstructure:"out-path"`
-	OutFile            *string   `yaml:"outFile" mapstructure:"out-file"`
-	ModelPkgName       *string   `yaml:"modelPkgName" mapstructure:"model-pkg-name"`
-	FieldWithStringTag *[]string `yaml:"fieldWithStringTag" mapstructure:"field-with-string-tag"`
-	OnlyModel          *bool     `yaml:"onlyModel" mapstructure:"only-model"`
-	WithUnitTest       *bool     `yaml:"withUnitTest" mapstructure:"with-unit-test"`
-	FieldNullable      *bool     `yaml:"fieldNullable" mapstructure:"field-nullable"`
-	FieldWithIndexTag  *bool     `yaml:"fieldWithIndexTag" mapstructure:"field-with-index-tag"`
-	FieldWithTypeTag   *bool     `yaml:"fieldWithTypeTag" mapstructure:"field-with-type-tag"`
-	FieldSignable      *bool     `yaml:"fieldSignable" mapstructure:"field-signable"`
+	DSN                         *string        `yaml:"dsn"`
+	DB                          *string        `yaml:"db"`
+	Tables                      *[]string      `yaml:"tables"`
+	Exclude                     *[]string      `yaml:"exclude"`
+	Association                 *[]Association `yaml:"association" mapstructure:"-"`
+	AssociationFromFK           *bool          `yaml:"associationFromFK" mapstructure:"association-from-fk"`
+	WithView                    *bool          `yaml:"withView" mapstructure:"with-view"`
+	OutPath                     *string        `yaml:"outPath" mapstructure:"out-path"`
+	OutFile                     *string        `yaml:"outFile" mapstructure:"out-file"`
+	ModelPkgName                *string        `yaml:"modelPkgName" mapstructure:"model-pkg-name"`
+	FieldWithStringTag          *[]string      `yaml:"fieldWithStringTag" mapstructure:"field-with-string-tag"`
+	FieldWithInt64String        *bool          `yaml:"fieldWithInt64String" mapstructure:"field-with-int64-string"`
+	FieldWithInt64StringExclude *[]string      `yaml:"fieldWithInt64StringExclude" mapstructure:"field-with-int64-string-exclude"`
+	OnlyModel                   *bool          `yaml:"onlyModel" mapstructure:"only-model"`
+	WithUnitTest                *bool          `yaml:"withUnitTest" mapstructure:"with-unit-test"`
+	FieldNullable               *bool          `yaml:"fieldNullable" mapstructure:"field-nullable"`
+	FieldWithIndexTag           *bool          `yaml:"fieldWithIndexTag" mapstructure:"field-with-index-tag"`
+	FieldWithTypeTag            *bool          `yaml:"fieldWithTypeTag" mapstructure:"field-with-type-tag"`
+	FieldSignable               *bool          `yaml:"fieldSignable" mapstructure:"field-signable"`
+	FromMigration               *bool          `yaml:"fromMigration" mapstructure:"from-migration"`
+	MigrateEnv                  *string        `yaml:"migrateEnv" mapstructure:"migrate-env"`
+	DataType                    *[]DataType    `yaml:"dataType" mapstructure:"data-type"`
+	FieldWithEnum               *bool          `yaml:"fieldWithEnum" mapstructure:"field-with-enum"`
+	Interface                   *[]Interface   `yaml:"interface"`
+	Sources                     *[]Source      `yaml:"sources"`
+	Schemas                     *[]string      `yaml:"schemas"`
+	SchemaLayout                *string        `yaml:"schemaLayout" mapstructure:"schema-layout"`
+	SoftDelete                  *[]SoftDelete  `yaml:"softDelete" mapstructure:"soft-delete"`
+	OptimisticLock              *[]Version     `yaml:"optimisticLock" mapstructure:"optimistic-lock"`
+	Column                      *[]ColumnRule  `yaml:"column"`
+	Shard                       *[]Shard       `yaml:"shard"`
+
+	// configPath is the gen.yml path, --fromMigration reads the migrate environment from it
+	configPath string
+	// source is the name of Sources item, empty if gen.yml has no sources
+	source string
+	// schema is the schema generated into its own package, empty for search path
+	schema string
+	// views are the views of search path, listed if WithView is set
+	views []string
 }
 
 type CmdParams struct {
 	Gen *CmdGenParams `yaml:"gen"`
 }
 
-type AssociationType struct {
-	TableName        string
-	Relation         string
-	FieldName        string
-	RelationshipType string
-	ForeignKey       string
-}
-
 // connectDB choose db type for connection to database
 func connectDB(t DBType, dsn string) (*gorm.DB, error) {
 	if dsn == "" {
@@ -149,80 +196,70 @@ func connectDB(t DBType, dsn string) (*gorm.DB, error) {
 
 // genModels is gorm/gen generated models
 func genModels(cfg *CmdGenParams) (err error) {
-	targetTables := *cfg.Tables
-	if len(targetTables) == 0 {
-		targetTables, err = newDB(cfg).Migrator().GetTables()
-		if err != nil {
-			return fmt.Errorf("GORM migrator get all tables fail: %w", err)
-		}
+	gormDB, closeDB, err := newDB(cfg)
+	if err != nil {
+		return
 	}
+	defer closeDB()
 
-	excludeTables := *cfg.Exclude
-	// get association tables by associationTables
-	for _, item := range *cfg.Association {
-		arr := strings.Split(item, "|")
-		if !utils.Contains[string](excludeTables, arr[0]) {
-			excludeTables = append(excludeTables, arr[0])
-		}
-		if !utils.Contains[string](excludeTables, arr[1]) {
-			excludeTables = append(excludeTables, arr[1])
-		}
+	err = withViews(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	err = withForeignKeyAssociations(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	selections, err := selectTables(cfg, gormDB)
+	if err != nil {
+		return
 	}
 
-	// remove excludeTables
-	simpleTables := make([]string, 0, len(targetTables))
-	for _, item := range targetTables {
-		if !utils.Contains[string](excludeTables, item) {
-			simpleTables = append(simpleTables, item)
+	// association tables are generated with opt below
+	simpleTables := make([]string, 0, len(selections))
+	for _, item := range selections {
+		if item.included && !item.association {
+			simpleTables = append(simpleTables, item.table)
 		}
 	}
 
 	var models []interface{}
-	g := newGenerator(cfg)
+	tableModels := make(map[string]interface{})
+	g := newGenerator(cfg, gormDB)
 	relations := make([]string, 0, len(*cfg.Association))
 	sources := make([]string, 0, len(*cfg.Association))
 	//var option gen.ModelOpt
 	associations := make(map[string][]gen.ModelOpt)
-	for _, item := range *cfg.Association {
-		arr := strings.Split(item, "|")
-		arr2 := strings.Split(arr[4], ":")
-		// 创建并保存AssociationType
-		at := AssociationType{
-			TableName:        arr[0],
-			Relation:         arr[1],
-			FieldName:        arr[2],
-			RelationshipType: arr[3],
-			ForeignKey:       arr2[1],
+	for _, at := range *cfg.Association {
+		if !cfg.inPass(at.Table) {
+			continue
 		}
-		tag := field.GormTag{}
-		tag.Set(arr2[0], arr2[1])
 		// save source and relation
 		if !utils.Contains[string](relations, at.Relation) {
 			relations = append(relations, at.Relation)
 		}
-		if !utils.Contains[string](sources, at.TableName) {
-			sources = append(sources, at.TableName)
+		if !utils.Contains[string](sources, at.Table) {
+			sources = append(sources, at.Table)
 		}
 
-		relationNs := needAddStringTag(cfg, at.Relation)
-		sourceNs := needAddStringTag(cfg, at.TableName)
 		// generate model with opt
-		associations[at.TableName] = append(associations[at.TableName], gen.FieldRelate(
-			field.RelationshipType(at.RelationshipType),
-			at.FieldName,
-			newGenerator(cfg).GenerateModel(at.Relation, gen.FieldJSONTagWithNS(relationNs)),
-			&field.RelateConfig{
-				GORMTag: tag,
-				// json tag use camel case
-				JSONTag: utils.CamelCaseLowerFirst(at.Relation),
-			},
-		))
-		associations[at.TableName] = append(associations[at.TableName], gen.FieldJSONTagWithNS(sourceNs))
-	}
-
-	for tableName, queryStructMetas := range associations {
-		m := g.GenerateModel(tableName, queryStructMetas...)
+		var opt gen.ModelOpt
+		opt, err = fieldRelate(cfg, gormDB, at)
+		if err != nil {
+			return
+		}
+		associations[at.Table] = append(associations[at.Table], opt)
+	}
+
+	for _, tableName := range sources {
+		var opts []gen.ModelOpt
+		opts, err = modelOpts(cfg, gormDB, tableName)
+		if err != nil {
+			return
+		}
+		m := g.GenerateModel(tableName, append(opts, associations[tableName]...)...)
 		models = append(models, m)
+		tableModels[tableName] = m
 	}
 
 	// relation in sources means generate model with opt, not in is simple
@@ -233,74 +270,205 @@ func genModels(cfg *CmdGenParams) (err error) {
 	}
 
 	for _, item := range simpleTables {
-		ns := needAddStringTag(cfg, item)
-		m := g.GenerateModel(item, gen.FieldJSONTagWithNS(ns))
+		var opts []gen.ModelOpt
+		opts, err = modelOpts(cfg, gormDB, item)
+		if err != nil {
+			return
+		}
+		m := g.GenerateModel(item, opts...)
 		models = append(models, m)
+		tableModels[item] = m
 	}
 
 	if !*cfg.OnlyModel {
 		g.ApplyInterface(func(filter.Filter) {}, models...)
+		err = applyInterfaces(cfg, g, append(sources, simpleTables...), tableModels)
+		if err != nil {
+			return
+		}
 	}
 
 	g.Execute()
+	err = genShards(cfg, gormDB, selections)
+	if err != nil {
+		return
+	}
+	if !*cfg.OnlyModel {
+		err = readOnlyViews(cfg, append(sources, simpleTables...))
+		if err != nil {
+			return
+		}
+	}
+	err = genEnums(cfg, gormDB, append(sources, simpleTables...))
 	return
 }
 
+// exitCheck exits with checkErrorCode if --check cannot run, so that CI tells it from stale code
+func exitCheck() {
+	if check {
+		os.Exit(checkErrorCode)
+	}
+}
+
 func run(cmd *cobra.Command, args []string) {
 	cfg, err := parseConfig(cmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\033[31mERROR: parse config fail: %s\033[m\n", err.Error())
+		exitCheck()
 		return
 	}
 
-	err = genModels(cfg)
+	configPath, _ := cmd.Flags().GetString("config")
+	names := make([]string, 0)
+	for _, item := range strings.Split(source, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			names = append(names, item)
+		}
+	}
+	sources, err := sourceConfigs(cfg, configPath, names)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "\033[31mERROR: Generate gorm failed: %s\033[m\n", err.Error())
+		fmt.Fprintf(os.Stderr, "\033[31mERROR: parse config fail: %s\033[m\n", err.Error())
+		exitCheck()
 		return
 	}
+	cfgs := make([]*CmdGenParams, 0, len(sources))
+	for _, item := range sources {
+		cfgs = append(cfgs, schemaConfigs(item)...)
+	}
 
-	fmt.Println("\n🍺 Generate gorm succeeded")
-	fmt.Printf("model path %s\n", color.GreenString(*cfg.OutPath))
-	fmt.Printf("query path %s\n", color.GreenString(*cfg.ModelPkgName))
+	if list {
+		for _, item := range cfgs {
+			printSource(item)
+			err = listModels(item)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\033[31mERROR: List gorm tables failed: %s\033[m\n", err.Error())
+				return
+			}
+		}
+		return
+	}
+
+	if check {
+		code := 0
+		for _, item := range cfgs {
+			printSource(item)
+			err = checkModels(item)
+			switch {
+			case errors.Is(err, errStale):
+				fmt.Fprintf(os.Stderr, "\033[31mERROR: Check gorm failed: %s\033[m\n", err.Error())
+				if code == 0 {
+					code = checkStaleCode
+				}
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "\033[31mERROR: Check gorm cannot run: %s\033[m\n", err.Error())
+				code = checkErrorCode
+			}
+		}
+		if code != 0 {
+			os.Exit(code)
+		}
+		fmt.Println("\n🍺 Generated gorm code is up to date")
+		return
+	}
+
+	for _, item := range cfgs {
+		printSource(item)
+		err = genModels(item)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\033[31mERROR: Generate gorm failed: %s\033[m\n", err.Error())
+			return
+		}
+
+		fmt.Println("\n🍺 Generate gorm succeeded")
+		fmt.Printf("model path %s\n", color.GreenString(*item.OutPath))
+		fmt.Printf("query path %s\n", color.GreenString(*item.ModelPkgName))
+	}
 }
 
-func newDB(cfg *CmdGenParams) *gorm.DB {
-	gormDB, err := connectDB(DBType(*cfg.DB), *cfg.DSN)
+func printSource(cfg *CmdGenParams) {
+	if cfg.source != "" {
+		fmt.Printf("source %s\n", color.GreenString(cfg.source))
+	}
+	if cfg.schema != "" {
+		fmt.Printf("schema %s\n", color.GreenString(cfg.schema))
+	}
+}
+
+func newDB(cfg *CmdGenParams) (gormDB *gorm.DB, closeDB func(), err error) {
+	if *cfg.FromMigration {
+		return migrationDB(cfg)
+	}
+	gormDB, err = connectDB(DBType(*cfg.DB), *cfg.DSN)
 	if err != nil {
-		log.Fatalln("connect db server fail:", err)
+		err = fmt.Errorf("connect db server fail: %w", err)
+		return
+	}
+	closeDB = func() {
+		if sqlDB, e := gormDB.DB(); e == nil {
+			_ = sqlDB.Close()
+		}
 	}
-	return gormDB
+	return
 }
 
 func generateWithOpts() {
 
 }
 
-func needAddStringTag(cfg *CmdGenParams, tableName string) func(columnName string) string {
+// modelOpts returns the options to generate model of table
+func modelOpts(cfg *CmdGenParams, gormDB *gorm.DB, table string) (opts []gen.ModelOpt, err error) {
+	int64Columns, err := int64StringColumns(cfg, gormDB, table)
+	if err != nil {
+		return
+	}
+	opts = append(opts, gen.FieldJSONTagWithNS(needAddStringTag(cfg, table, int64Columns)))
+	enums, err := enumOpts(cfg, gormDB, table)
+	if err != nil {
+		return
+	}
+	// data type mapping of gen.yml is prior to enum
+	opts = append(opts, enums...)
+	typeOpts, err := dataTypeOpts(cfg, gormDB, table)
+	if err != nil {
+		return
+	}
+	opts = append(opts, typeOpts...)
+	// soft delete type is prior to data type mapping
+	opts = append(opts, softDeleteOpts(cfg, table)...)
+	opts = append(opts, versionOpts(cfg, table)...)
+	// column rules are the last so that json tag of them is prior to field-with-string-tag
+	opts = append(opts, columnOpts(cfg, table)...)
+	return
+}
+
+func needAddStringTag(cfg *CmdGenParams, tableName string, int64Columns []string) func(columnName string) string {
+	newArr := make([]string, 0, len(int64Columns))
+	newArr = append(newArr, int64Columns...)
 	for _, item := range *cfg.FieldWithStringTag {
 		arr := strings.Split(item, "|")
 		if arr[0] == tableName {
-			newArr := make([]string, len(arr)-1, len(arr)-1)
-			copy(newArr, arr[1:])
-			return func(columnName string) string {
-				if columnName == "id" {
-					return "id,string"
-				}
-				tag := utils.CamelCaseLowerFirst(columnName)
-				for _, v := range newArr {
-					if v == columnName {
-						tag = tag + ",string"
-						break
-					}
-				}
-				return tag
+			newArr = append(newArr, arr[1:]...)
+		}
+	}
+	if len(newArr) == 0 {
+		return nil
+	}
+	return func(columnName string) string {
+		if columnName == "id" {
+			return "id,string"
+		}
+		tag := utils.CamelCaseLowerFirst(columnName)
+		for _, v := range newArr {
+			if v == columnName {
+				tag = tag + ",string"
+				break
 			}
 		}
+		return tag
 	}
-	return nil
 }
 
-func newGenerator(cfg *CmdGenParams) *gen.Generator {
+func newGenerator(cfg *CmdGenParams, gormDB *gorm.DB) *gen.Generator {
 	g := gen.NewGenerator(gen.Config{
 		OutPath:           *cfg.OutPath,
 		OutFile:           *cfg.OutFile,
@@ -311,50 +479,64 @@ func newGenerator(cfg *CmdGenParams) *gen.Generator {
 		FieldWithTypeTag:  *cfg.FieldWithTypeTag,
 		FieldSignable:     *cfg.FieldSignable,
 	})
-	g.UseDB(newDB(cfg))
-	var dataMap = map[string]func(gorm.ColumnType) (dataType string){
-		"decimal": func(columnType gorm.ColumnType) (dataType string) {
-			return "decimal.Decimal"
-		},
-		"datetime": func(columnType gorm.ColumnType) (dataType string) {
-			return "carbon.DateTime"
-		},
-		"date": func(columnType gorm.ColumnType) (dataType string) {
-			return "carbon.Date"
-		},
-	}
-
-	g.WithDataTypeMap(dataMap)
-	g.WithImportPkgPath("github.com/golang-module/carbon/v2")
+	g.UseDB(gormDB)
+	g.WithModelNameStrategy(func(tableName string) string {
+		return modelName(cfg, gormDB, tableName)
+	})
+	g.WithFileNameStrategy(func(tableName string) string {
+		return fileName(cfg, tableName)
+	})
+	g.WithDataTypeMap(dataTypeMap(cfg))
+	g.WithImportPkgPath(dataTypeImports(cfg)...)
+	g.WithImportPkgPath(softDeleteImports(cfg)...)
+	g.WithImportPkgPath(versionImports(cfg)...)
 	g.WithJSONTagNameStrategy(func(columnName string) string {
 		if columnName == "id" {
 			return "id,string"
 		}
 		return utils.CamelCaseLowerFirst(columnName)
 	})
-	// support gorm soft delete
-	g.WithOpts(gen.FieldType("deleted_at", "gorm.DeletedAt"))
 	return g
 }
 
 func parseConfig(cmd *cobra.Command) (*CmdGenParams, error) {
+	configPath, _ := cmd.Flags().GetString("config")
+	return loadConfig(configPath)
+}
+
+// loadConfig reads gen of configPath over the defaults of flags, gen gorm, docs, biz and data read the same config
+func loadConfig(configPath string) (*CmdGenParams, error) {
 	viper.SetDefault("gen.dsn", dsn)
 	viper.SetDefault("gen.db", db)
 	viper.SetDefault("gen.tables", tables)
 	viper.SetDefault("gen.exclude", exclude)
-	viper.SetDefault("gen.association", association)
+	viper.SetDefault("gen.association-from-fk", associationFromFK)
+	viper.SetDefault("gen.with-view", withView)
 	viper.SetDefault("gen.out-path", outPath)
 	viper.SetDefault("gen.out-file", outFile)
 	viper.SetDefault("gen.model-pkg-name", modelPkgName)
 	viper.SetDefault("gen.field-with-string-tag", fieldWithStringTag)
+	viper.SetDefault("gen.field-with-int64-string", fieldWithInt64String)
+	viper.SetDefault("gen.field-with-int64-string-exclude", fieldWithInt64StringExclude)
 	viper.SetDefault("gen.only-model", onlyModel)
 	viper.SetDefault("gen.with-unit-test", withUnitTest)
 	viper.SetDefault("gen.field-nullable", fieldNullable)
 	viper.SetDefault("gen.field-with-index-tag", fieldWithIndexTag)
 	viper.SetDefault("gen.field-with-type-tag", fieldWithTypeTag)
 	viper.SetDefault("gen.field-signable", fieldSignable)
+	viper.SetDefault("gen.from-migration", fromMigration)
+	viper.SetDefault("gen.migrate-env", migrateEnv)
+	viper.SetDefault("gen.data-type", []DataType{})
+	viper.SetDefault("gen.field-with-enum", fieldWithEnum)
+	viper.SetDefault("gen.interface", []Interface{})
+	viper.SetDefault("gen.sources", []Source{})
+	viper.SetDefault("gen.schemas", []string{})
+	viper.SetDefault("gen.schema-layout", schemaLayoutPrefix)
+	viper.SetDefault("gen.soft-delete", []SoftDelete{})
+	viper.SetDefault("gen.optimistic-lock", []Version{})
+	viper.SetDefault("gen.column", []ColumnRule{})
+	viper.SetDefault("gen.shard", []Shard{})
 
-	configPath, _ := cmd.Flags().GetString("config")
 	if configPath != "" {
 		viper.SetConfigFile(configPath)
 		// if yml value not exist use default value
@@ -365,21 +547,66 @@ func parseConfig(cmd *cobra.Command) (*CmdGenParams, error) {
 	if err != nil {
 		return nil, fmt.Errorf("viper failed to parse config: %w", err)
 	}
-	for _, item := range *cfg.Gen.Association {
-		arr := strings.Split(item, "|")
-		if len(arr) != 5 {
-			return nil, errors.Errorf("invalid association tables: %s", item)
+	associations, err := parseAssociations(configPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Gen.Association = &associations
+	cfg.Gen.configPath = configPath
+	err = cfg.Gen.validate()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Gen, nil
+}
+
+func (cfg *CmdGenParams) validate() (err error) {
+	for _, item := range *cfg.DataType {
+		err = item.validate()
+		if err != nil {
+			return
+		}
+	}
+	for _, item := range *cfg.Interface {
+		err = item.validate()
+		if err != nil {
+			return
 		}
-		arr2 := strings.Split(arr[4], ":")
-		if len(arr2) != 2 {
-			return nil, errors.Errorf("invalid association tables gorm tag: %s", arr[4])
+	}
+	for _, item := range *cfg.Column {
+		err = item.validate()
+		if err != nil {
+			return
 		}
 	}
-	for _, item := range *cfg.Gen.FieldWithStringTag {
+	for _, item := range *cfg.Shard {
+		err = item.validate()
+		if err != nil {
+			return
+		}
+	}
+	err = validateTablePatterns(*cfg.Tables)
+	if err != nil {
+		return
+	}
+	err = validateTablePatterns(*cfg.Exclude)
+	if err != nil {
+		return
+	}
+	err = normalizeSoftDeletes(*cfg.SoftDelete)
+	if err != nil {
+		return
+	}
+	normalizeVersions(*cfg.OptimisticLock)
+	if !utils.Contains[string](schemaLayouts, *cfg.SchemaLayout) {
+		return errors.Errorf("invalid schema layout %q, support %s", *cfg.SchemaLayout, strings.Join(schemaLayouts, "/"))
+	}
+	for _, item := range *cfg.FieldWithStringTag {
 		arr := strings.Split(item, "|")
 		if len(arr) <= 1 {
-			return nil, errors.Errorf("invalid field with string tag: %s", item)
+			return errors.Errorf("invalid field with string tag: %s", item)
 		}
 	}
-	return cfg.Gen, nil
+	err = validateInt64StringExclude(*cfg.FieldWithInt64StringExclude)
+	return
 }
@@ -0,0 +1,219 @@
+package gorm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// notSupported is the error of gorm migrator if driver does not implement the method
+const notSupported = "not support"
+
+// Table is a table of database, it is discovered and associated as gen gorm does, used by cinch gen docs
+type Table struct {
+	// Source is the name of gen.yml sources, empty if gen.yml has no sources
+	Source       string
+	Name         string
+	Model        string
+	Comment      string
+	View         bool
+	Columns      []Column
+	Indexes      []Index
+	Associations []Association
+}
+
+// Column is a column of Table
+type Column struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	Default    *string
+	Comment    string
+	PrimaryKey bool
+}
+
+// Index is an index of Table
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+// Inspect returns the tables of gen.yml sources selected by names, cmd provides the config flag
+func Inspect(cmd *cobra.Command, names []string) (list []Table, err error) {
+	cfg, err := parseConfig(cmd)
+	if err != nil {
+		return
+	}
+	configPath, _ := cmd.Flags().GetString("config")
+	sources, err := sourceConfigs(cfg, configPath, names)
+	if err != nil {
+		return
+	}
+	for _, item := range sources {
+		for _, c := range schemaConfigs(item) {
+			var tables []Table
+			tables, err = inspectTables(c)
+			if err != nil {
+				return
+			}
+			list = append(list, tables...)
+		}
+	}
+	return
+}
+
+func inspectTables(cfg *CmdGenParams) (list []Table, err error) {
+	gormDB, closeDB, err := newDB(cfg)
+	if err != nil {
+		return
+	}
+	defer closeDB()
+
+	err = withViews(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	err = withForeignKeyAssociations(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	selections, err := selectTables(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	for _, item := range selections {
+		if !item.included {
+			continue
+		}
+		var table Table
+		table, err = inspectTable(cfg, gormDB, item.table)
+		if err != nil {
+			return
+		}
+		list = append(list, table)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return
+}
+
+func inspectTable(cfg *CmdGenParams, gormDB *gorm.DB, name string) (table Table, err error) {
+	table = Table{
+		Source: cfg.source,
+		Name:   name,
+		Model:  modelName(cfg, gormDB, name),
+		View:   isView(cfg, name),
+	}
+	for _, a := range *cfg.Association {
+		if a.Table == name {
+			table.Associations = append(table.Associations, a)
+		}
+	}
+	cols, err := gormDB.Migrator().ColumnTypes(name)
+	if err != nil {
+		err = fmt.Errorf("GORM migrator get columns of %s fail: %w", name, err)
+		return
+	}
+	for _, col := range cols {
+		item := Column{
+			Name: col.Name(),
+			Type: col.DatabaseTypeName(),
+		}
+		if v, ok := col.ColumnType(); ok && v != "" {
+			item.Type = v
+		}
+		item.Nullable, _ = col.Nullable()
+		item.PrimaryKey, _ = col.PrimaryKey()
+		item.Comment, _ = col.Comment()
+		if v, ok := col.DefaultValue(); ok {
+			item.Default = &v
+		}
+		table.Columns = append(table.Columns, item)
+	}
+	tableType, err := gormDB.Migrator().TableType(name)
+	switch {
+	case err == nil:
+		table.Comment, _ = tableType.Comment()
+	case err.Error() != notSupported:
+		err = fmt.Errorf("GORM migrator get comment of %s fail: %w", name, err)
+		return
+	}
+	table.Indexes, err = inspectIndexes(gormDB, name)
+	return
+}
+
+// isView reports whether table is a view listed by WithView
+func isView(cfg *CmdGenParams, table string) bool {
+	return utils.Contains[string](cfg.views, table)
+}
+
+// inspectIndexes returns indexes of table, sqlite migrator does not support GetIndexes, PRAGMA is used
+func inspectIndexes(gormDB *gorm.DB, table string) (list []Index, err error) {
+	if gormDB.Dialector.Name() == "sqlite" {
+		return sqliteIndexes(gormDB, table)
+	}
+	indexes, err := gormDB.Migrator().GetIndexes(table)
+	if err != nil {
+		if err.Error() == notSupported {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("GORM migrator get indexes of %s fail: %w", table, err)
+		return
+	}
+	for _, idx := range indexes {
+		item := Index{
+			Name:    idx.Name(),
+			Columns: idx.Columns(),
+		}
+		item.Unique, _ = idx.Unique()
+		item.Primary, _ = idx.PrimaryKey()
+		list = append(list, item)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return
+}
+
+func sqliteIndexes(gormDB *gorm.DB, table string) (list []Index, err error) {
+	var indexes []struct {
+		Name   string `gorm:"column:name"`
+		Unique bool   `gorm:"column:unique"`
+		Origin string `gorm:"column:origin"`
+	}
+	err = gormDB.Raw(fmt.Sprintf("PRAGMA index_list(%q)", table)).Scan(&indexes).Error
+	if err != nil {
+		err = fmt.Errorf("get indexes of %s fail: %w", table, err)
+		return
+	}
+	for _, idx := range indexes {
+		var cols []struct {
+			Name string `gorm:"column:name"`
+		}
+		err = gormDB.Raw(fmt.Sprintf("PRAGMA index_info(%q)", idx.Name)).Scan(&cols).Error
+		if err != nil {
+			err = fmt.Errorf("get index %s of %s fail: %w", idx.Name, table, err)
+			return
+		}
+		item := Index{
+			Name:    idx.Name,
+			Unique:  idx.Unique,
+			Primary: idx.Origin == "pk",
+		}
+		for _, col := range cols {
+			item.Columns = append(item.Columns, col.Name)
+		}
+		list = append(list, item)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return
+}
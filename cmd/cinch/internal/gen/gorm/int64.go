@@ -0,0 +1,56 @@
+package gorm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// int64DBTypes are 64-bit integer column types of supported databases, unsigned suffix is trimmed before matching
+var int64DBTypes = []string{"bigint", "int8", "bigserial", "serial8", "int64", "uint64"}
+
+// isInt64Column reports whether the database type of col is a 64-bit integer
+func isInt64Column(col gorm.ColumnType) bool {
+	name := strings.ToLower(strings.TrimSpace(col.DatabaseTypeName()))
+	name = strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(name, "unsigned"), "unsigned"))
+	return utils.Contains[string](int64DBTypes, name)
+}
+
+// validateInt64StringExclude validates items of field-with-int64-string-exclude, item is column or table|column
+func validateInt64StringExclude(list []string) error {
+	for _, item := range list {
+		arr := strings.Split(item, "|")
+		if len(arr) > 2 || utils.Contains[string](arr, "") {
+			return errors.Errorf("invalid field with int64 string exclude: %s", item)
+		}
+	}
+	return nil
+}
+
+// int64StringColumns returns 64-bit integer columns of table which need ,string json tag,
+// javascript loses precision of numbers greater than 2^53
+func int64StringColumns(cfg *CmdGenParams, gormDB *gorm.DB, table string) (list []string, err error) {
+	if !*cfg.FieldWithInt64String {
+		return
+	}
+	cols, err := gormDB.Migrator().ColumnTypes(table)
+	if err != nil {
+		err = fmt.Errorf("GORM migrator get columns of %s fail: %w", table, err)
+		return
+	}
+	for _, col := range cols {
+		if !isInt64Column(col) {
+			continue
+		}
+		name := col.Name()
+		if utils.Contains[string](*cfg.FieldWithInt64StringExclude, name) ||
+			utils.Contains[string](*cfg.FieldWithInt64StringExclude, table+"|"+name) {
+			continue
+		}
+		list = append(list, name)
+	}
+	return
+}
@@ -0,0 +1,370 @@
+package gorm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gen"
+)
+
+// Interface is a custom query interface applied to tables, the methods use gorm/gen SQL comment syntax,
+// consult[https://gorm.io/gen/dynamic_sql.html]. In gen.yml:
+//
+//	interface:
+//	  - name: UserQuery
+//	    dir: internal/data/query/iface
+//	    tables: [user]
+//	  - name: ReportQuery
+//	    sql: internal/data/query/sql
+//	    tables: [order, order_item]
+//
+// dir is a go package which declares the interface Name, sql is a .sql file or a dir of .sql files,
+// every method is declared by a "-- method:" line and followed by its SQL:
+//
+//	-- import: github.com/shopspring/decimal
+//	-- method: SalesByDay(start, end time.Time) ([]gen.M, error)
+//	SELECT DATE(created_at) AS day, SUM(amount) AS total FROM @@table
+//	{{where}} created_at BETWEEN @start AND @end {{end}}
+//	GROUP BY day
+//
+// types in method signatures must be qualified by package, gen and time are imported by default.
+// Tables is all generated models if empty.
+type Interface struct {
+	Name   string   `yaml:"name"`
+	Dir    string   `yaml:"dir"`
+	SQL    string   `yaml:"sql"`
+	Tables []string `yaml:"tables"`
+}
+
+// customInterface is a placeholder, gorm/gen reads interface methods from source file of a compiled type,
+// the configured interface is written as customInterface into a temp module before ApplyInterface
+type customInterface interface{}
+
+var customInterfaceType = reflect.TypeOf((*customInterface)(nil)).Elem()
+
+const (
+	sqlImportPrefix = "-- import:"
+	sqlMethodPrefix = "-- method:"
+)
+
+var defaultSQLImports = []string{"gorm.io/gen", "time"}
+
+func (it Interface) validate() error {
+	switch {
+	case it.Name == "":
+		return errors.Errorf("invalid interface %+v: name is required", it)
+	case it.Dir == "" && it.SQL == "":
+		return errors.Errorf("invalid interface %s: dir or sql is required", it.Name)
+	case it.Dir != "" && it.SQL != "":
+		return errors.Errorf("invalid interface %s: dir and sql cannot be used together", it.Name)
+	}
+	return nil
+}
+
+// source returns go source of the interface renamed as customInterface
+func (it Interface) source() ([]byte, error) {
+	if it.Dir != "" {
+		return interfaceFromPackage(it.Dir, it.Name)
+	}
+	return interfaceFromSQL(it.SQL)
+}
+
+// interfaceFromPackage finds the interface declaration in dir, the file is copied with the type renamed
+func interfaceFromPackage(dir, name string) (src []byte, err error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		err = fmt.Errorf("parse interface dir %s fail: %w", dir, err)
+		return
+	}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			spec := findInterfaceSpec(f, name)
+			if spec == nil {
+				continue
+			}
+			spec.Name.Name = customInterfaceType.Name()
+			f.Name.Name = filepath.Base(customInterfaceType.PkgPath())
+			var buf bytes.Buffer
+			err = printer.Fprint(&buf, fset, f)
+			src = buf.Bytes()
+			return
+		}
+	}
+	err = errors.Errorf("interface %s not found in %s", name, dir)
+	return
+}
+
+func findInterfaceSpec(f *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok = ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+				return ts
+			}
+		}
+	}
+	return nil
+}
+
+// sqlMethod is a method declared in .sql file
+type sqlMethod struct {
+	signature string
+	sql       []string
+	file      string
+	line      int
+}
+
+// interfaceFromSQL builds the interface from a .sql file or all .sql files of a dir
+func interfaceFromSQL(p string) (src []byte, err error) {
+	files := []string{p}
+	info, err := os.Stat(p)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(p, "*.sql"))
+		if err != nil {
+			return
+		}
+		sort.Strings(files)
+	}
+	imports := append([]string{}, defaultSQLImports...)
+	var methods []sqlMethod
+	for _, file := range files {
+		var (
+			list  []sqlMethod
+			paths []string
+		)
+		list, paths, err = parseSQLFile(file)
+		if err != nil {
+			return
+		}
+		methods = append(methods, list...)
+		for _, item := range paths {
+			if !utils.Contains[string](imports, item) {
+				imports = append(imports, item)
+			}
+		}
+	}
+	if len(methods) == 0 {
+		err = errors.Errorf("no %q found in %s", sqlMethodPrefix, p)
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n", filepath.Base(customInterfaceType.PkgPath()))
+	for _, item := range imports {
+		fmt.Fprintf(&buf, "\t%s\n", strconv.Quote(item))
+	}
+	fmt.Fprintf(&buf, ")\n\ntype %s interface {\n", customInterfaceType.Name())
+	for _, m := range methods {
+		for _, line := range m.sql {
+			fmt.Fprintf(&buf, "\t// %s\n", line)
+		}
+		fmt.Fprintf(&buf, "\t%s\n", m.signature)
+	}
+	buf.WriteString("}\n")
+	src, err = format.Source(buf.Bytes())
+	if err != nil {
+		err = fmt.Errorf("build interface from %s fail: %w", p, err)
+	}
+	return
+}
+
+// parseSQLFile reads methods and imports of file, other "--" comments are skipped
+func parseSQLFile(file string) (methods []sqlMethod, imports []string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(text, sqlImportPrefix):
+			imports = append(imports, strings.Trim(strings.TrimSpace(strings.TrimPrefix(text, sqlImportPrefix)), `"`))
+		case strings.HasPrefix(text, sqlMethodPrefix):
+			m := sqlMethod{
+				signature: strings.TrimSpace(strings.TrimPrefix(text, sqlMethodPrefix)),
+				file:      file,
+				line:      line,
+			}
+			err = m.validate()
+			if err != nil {
+				return
+			}
+			methods = append(methods, m)
+		case text == "" || strings.HasPrefix(text, "--"):
+		case len(methods) == 0:
+			err = errors.Errorf("%s:%d: SQL need a %q line before it", file, line, sqlMethodPrefix)
+			return
+		default:
+			methods[len(methods)-1].sql = append(methods[len(methods)-1].sql, text)
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+	for _, m := range methods {
+		if len(m.sql) == 0 {
+			err = errors.Errorf("%s:%d: method has no SQL", m.file, m.line)
+			return
+		}
+	}
+	return
+}
+
+// validate makes sure signature is a go interface method
+func (m sqlMethod) validate() error {
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p\ntype _ interface {\n"+m.signature+"\n}", 0)
+	if err == nil {
+		it := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.InterfaceType)
+		if len(it.Methods.List) == 1 && len(it.Methods.List[0].Names) == 1 {
+			return nil
+		}
+	}
+	return errors.Errorf("%s:%d: invalid method %q, Example: -- method: FindByName(name string) ([]gen.T, error)", m.file, m.line, m.signature)
+}
+
+// applyInterfaces applies the configured interfaces to the generated models, tableModels is table name to model
+func applyInterfaces(cfg *CmdGenParams, g *gen.Generator, tables []string, tableModels map[string]interface{}) (err error) {
+	for _, it := range *cfg.Interface {
+		models := make([]interface{}, 0, len(it.Tables))
+		list := it.Tables
+		if len(list) == 0 {
+			list = tables
+		}
+		for _, table := range list {
+			if !cfg.inPass(table) {
+				continue
+			}
+			m, ok := tableModels[table]
+			if !ok {
+				err = errors.Errorf("interface %s: table %s is not generated", it.Name, table)
+				return
+			}
+			models = append(models, m)
+		}
+		err = applyInterface(g, it, models)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// applyInterface writes the interface as the package of customInterface into a temp module inside the project module,
+// gorm/gen finds the package by go list, which reads a copy of go.mod that replaces the package path with the temp module
+func applyInterface(g *gen.Generator, it Interface, models []interface{}) (err error) {
+	src, err := it.source()
+	if err != nil {
+		return
+	}
+	root, err := moduleRoot()
+	if err != nil {
+		err = fmt.Errorf("apply interface %s fail: %w", it.Name, err)
+		return
+	}
+	// go tools ignore the directory which starts with a dot, so it is not a package of the project
+	tmp, err := os.MkdirTemp(root, ".cinch-interface-")
+	if err != nil {
+		err = fmt.Errorf("create temp dir fail: %w", err)
+		return
+	}
+	defer os.RemoveAll(tmp)
+	pkgPath := customInterfaceType.PkgPath()
+	err = os.WriteFile(filepath.Join(tmp, "go.mod"), []byte(fmt.Sprintf("module %s\n\ngo 1.18\n", pkgPath)), 0640)
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(filepath.Join(tmp, "interface.go"), src, 0640)
+	if err != nil {
+		return
+	}
+	modFile, err := replaceModFile(root, tmp, pkgPath)
+	if err != nil {
+		return
+	}
+
+	flags, ok := os.LookupEnv("GOFLAGS")
+	os.Setenv("GOFLAGS", strings.TrimSpace(flags+" -modfile="+modFile))
+	defer func() {
+		if ok {
+			os.Setenv("GOFLAGS", flags)
+		} else {
+			os.Unsetenv("GOFLAGS")
+		}
+		// gorm/gen logs the reason and panics
+		if r := recover(); r != nil {
+			err = errors.Errorf("apply interface %s fail: %v", it.Name, r)
+		}
+	}()
+	g.ApplyInterface(func(customInterface) {}, models...)
+	return
+}
+
+// moduleRoot returns the directory of go.mod which contains the working directory
+func moduleRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err = os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("go.mod not found, interface needs a go module")
+		}
+		dir = parent
+	}
+}
+
+// replaceModFile writes go.mod and go.sum of root into dir, pkgPath is required and replaced by dir
+func replaceModFile(root, dir, pkgPath string) (modFile string, err error) {
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return
+	}
+	mod = append(mod, fmt.Sprintf("\nrequire %s v0.0.0\n\nreplace %s => %s\n", pkgPath, pkgPath, dir)...)
+	modFile = filepath.Join(dir, "project.mod")
+	err = os.WriteFile(modFile, mod, 0640)
+	if err != nil {
+		return
+	}
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	} else if err != nil {
+		return
+	}
+	err = os.WriteFile(filepath.Join(dir, "project.sum"), sum, 0640)
+	return
+}
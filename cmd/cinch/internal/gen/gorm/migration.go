@@ -0,0 +1,126 @@
+package gorm
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/go-cinch/cinch/cmd/cinch/internal/gen/migrate"
+	"github.com/go-cinch/common/utils"
+	sqlmigrate "github.com/rubenv/sql-migrate"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const migrationDBName = "gen"
+
+// migrationDB applies all up migrations of the migrate environment to a throwaway in-process database,
+// the models are generated from it, so the migration files stay the single source of truth
+func migrationDB(cfg *CmdGenParams) (gormDB *gorm.DB, closeDB func(), err error) {
+	migrate.ConfigFile = cfg.configPath
+	migrate.ConfigEnvironment = *cfg.MigrateEnv
+	env, err := migrate.GetSourceEnvironment()
+	if err != nil {
+		err = fmt.Errorf("could not parse migrate config: %w", err)
+		return
+	}
+
+	var (
+		t   DBType
+		dsn string
+	)
+	switch env.Dialect {
+	case "mysql":
+		t = dbMySQL
+		dsn, closeDB, err = startMySQLServer()
+	case "sqlite3":
+		t = dbSQLite
+		dsn, closeDB, err = tempSQLiteFile()
+	default:
+		err = fmt.Errorf("unsupported dialect %q for --fromMigration (support mysql || sqlite3 for now)", env.Dialect)
+	}
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			closeDB()
+		}
+	}()
+
+	sqlDB, err := sql.Open(env.Dialect, dsn)
+	if err != nil {
+		err = fmt.Errorf("cannot connect to in-process database: %w", err)
+		return
+	}
+	n, err := sqlmigrate.Exec(sqlDB, env.Dialect, sqlmigrate.FileMigrationSource{Dir: env.Dir}, sqlmigrate.Up)
+	_ = sqlDB.Close()
+	if err != nil {
+		err = fmt.Errorf("apply migrations from %s fail: %w", env.Dir, err)
+		return
+	}
+	fmt.Printf("applied %d migrations from %s\n", n, env.Dir)
+
+	gormDB, err = connectDB(t, dsn)
+	if err != nil {
+		err = fmt.Errorf("connect in-process database fail: %w", err)
+		return
+	}
+	stop := closeDB
+	closeDB = func() {
+		if conn, e := gormDB.DB(); e == nil {
+			_ = conn.Close()
+		}
+		stop()
+	}
+	// generated code depends on db type, keep it same as the migration dialect
+	*cfg.DB = string(t)
+	// the migrate record, checksum and lock tables are created by ourselves, they are not models
+	for _, table := range env.Tables() {
+		if !utils.Contains[string](*cfg.Exclude, table) {
+			*cfg.Exclude = append(*cfg.Exclude, table)
+		}
+	}
+	return
+}
+
+// startMySQLServer starts a MySQL compatible in-memory server on a random local port
+func startMySQLServer() (dsn string, closeDB func(), err error) {
+	// the server logs every connection as info
+	logrus.SetLevel(logrus.WarnLevel)
+	engine := sqle.NewDefault(memory.NewDBProvider(memory.NewDatabase(migrationDBName)))
+	s, err := server.NewDefaultServer(server.Config{
+		Protocol: "tcp",
+		Address:  "127.0.0.1:0",
+	}, engine)
+	if err != nil {
+		err = fmt.Errorf("start in-process mysql server fail: %w", err)
+		return
+	}
+	go func() {
+		_ = s.Start()
+	}()
+	dsn = fmt.Sprintf("root@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", s.Listener.Addr().String(), migrationDBName)
+	closeDB = func() {
+		_ = s.Close()
+	}
+	return
+}
+
+// tempSQLiteFile creates a sqlite database file in a temp dir, removed on close
+func tempSQLiteFile() (dsn string, closeDB func(), err error) {
+	dir, err := os.MkdirTemp("", "cinch-gen-")
+	if err != nil {
+		err = fmt.Errorf("create temp dir fail: %w", err)
+		return
+	}
+	dsn = filepath.Join(dir, migrationDBName+".db")
+	closeDB = func() {
+		_ = os.RemoveAll(dir)
+	}
+	return
+}
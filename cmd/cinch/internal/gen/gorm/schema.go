@@ -0,0 +1,150 @@
+package gorm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// schemaLayoutPrefix generates tables of schemas into the same package, model name is prefixed by schema,
+	// Example: sales.order => SalesOrder
+	schemaLayoutPrefix = "prefix"
+	// schemaLayoutPackage generates tables of a schema into its own package, Example: internal/data/query/sales
+	schemaLayoutPackage = "package"
+)
+
+var schemaLayouts = []string{schemaLayoutPrefix, schemaLayoutPackage}
+
+// currentSchemaSQL returns the schema of search path, tables in it are generated without schema as before
+var currentSchemaSQL = map[string]string{
+	"postgres":  "SELECT CURRENT_SCHEMA()",
+	"sqlserver": "SELECT SCHEMA_NAME()",
+}
+
+// splitTable splits "schema.table", schema is empty if table is not qualified
+func splitTable(table string) (schemaName, name string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// schemaConfigs returns cfg and one config per schema for package layout, the schema package is under OutPath/ModelPkgName
+func schemaConfigs(cfg *CmdGenParams) (list []*CmdGenParams) {
+	list = append(list, cfg)
+	if *cfg.SchemaLayout != schemaLayoutPackage {
+		return
+	}
+	for _, s := range *cfg.Schemas {
+		item := cfg.merge(CmdGenParams{})
+		item.source = cfg.source
+		item.schema = s
+		outPath := filepath.Join(*cfg.OutPath, s)
+		modelPkgName := filepath.Join(modelDir(*cfg.OutPath, *cfg.ModelPkgName), s)
+		outFile := ""
+		if *cfg.OutFile != "" {
+			outFile = filepath.Base(*cfg.OutFile)
+		}
+		item.OutPath = &outPath
+		item.ModelPkgName = &modelPkgName
+		item.OutFile = &outFile
+		list = append(list, item)
+	}
+	return
+}
+
+// inPass reports whether table is generated by cfg, tables of schemas are generated by their own config in package layout
+func (cfg *CmdGenParams) inPass(table string) bool {
+	if *cfg.SchemaLayout != schemaLayoutPackage {
+		return true
+	}
+	s, _ := splitTable(table)
+	if !utils.Contains[string](*cfg.Schemas, s) {
+		s = ""
+	}
+	return s == cfg.schema
+}
+
+// listTables returns tables of cfg.Tables if they are exact names, or all tables of search path and schemas, views of search path are included if listed
+func listTables(cfg *CmdGenParams, gormDB *gorm.DB) (list []string, err error) {
+	if len(*cfg.Tables) > 0 && literalTables(*cfg.Tables) {
+		for _, item := range *cfg.Tables {
+			if cfg.inPass(item) {
+				list = append(list, item)
+			}
+		}
+		return
+	}
+	if cfg.schema == "" {
+		list, err = gormDB.Migrator().GetTables()
+		if err != nil {
+			err = fmt.Errorf("GORM migrator get all tables fail: %w", err)
+			return
+		}
+		list = append(list, cfg.views...)
+		if len(*cfg.Schemas) == 0 || *cfg.SchemaLayout == schemaLayoutPackage {
+			return
+		}
+	}
+	query, ok := currentSchemaSQL[gormDB.Dialector.Name()]
+	if !ok {
+		err = errors.Errorf("schemas is not supported by %s (support postgres || sqlserver for now)", gormDB.Dialector.Name())
+		return
+	}
+	var current string
+	err = gormDB.Raw(query).Scan(&current).Error
+	if err != nil {
+		err = fmt.Errorf("get current schema fail: %w", err)
+		return
+	}
+	for _, s := range *cfg.Schemas {
+		// tables of search path are listed by GetTables already
+		if (cfg.schema == "" && s == current) || (cfg.schema != "" && s != cfg.schema) {
+			continue
+		}
+		var names []string
+		err = gormDB.Raw(
+			"SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_type = ? ORDER BY table_name",
+			s, "BASE TABLE",
+		).Scan(&names).Error
+		if err != nil {
+			err = fmt.Errorf("get tables of schema %s fail: %w", s, err)
+			return
+		}
+		for _, name := range names {
+			list = append(list, s+"."+name)
+		}
+	}
+	return
+}
+
+// modelName is the model struct name of table, qualified table is prefixed by schema unless it has own package,
+// shard table is named by its logical table
+func modelName(cfg *CmdGenParams, gormDB *gorm.DB, table string) string {
+	if logical, ok := shardTable(cfg, table); ok {
+		table = logical
+	}
+	s, name := splitTable(table)
+	if s == "" || cfg.schema != "" {
+		return gormDB.Config.NamingStrategy.SchemaName(name)
+	}
+	return schema.NamingStrategy{SingularTable: true}.SchemaName(s) + gormDB.Config.NamingStrategy.SchemaName(name)
+}
+
+// fileName is the generated file name of table without suffix
+func fileName(cfg *CmdGenParams, table string) string {
+	if logical, ok := shardTable(cfg, table); ok {
+		table = logical
+	}
+	s, name := splitTable(table)
+	if s == "" || cfg.schema != "" {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(s + "_" + name)
+}
@@ -0,0 +1,217 @@
+package gorm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Shard collapses the shard tables of a logical table into one model, in gen.yml:
+//
+//	shard:
+//	  - table: order
+//	  - table: log
+//	    pattern: log_2023*
+//	    format: log_2023%02d
+//	    start: 1
+//	    count: 12
+//
+// pattern is a glob or regex like tables, default matches table_0...table_N,
+// format is the fmt format of shard table name by key, default is derived from the first shard table, Example: order_%02d,
+// start is the number of the first shard, default is parsed from the first shard table by format, so log_202301...log_202312 above,
+// count is the number of shards, default is the number of shard tables.
+// model and query are generated from the first shard table with name of table,
+// model has ShardTable(key) and query has Shard(key) so that it works with any shard: q.Order.Shard(userID).WithContext(ctx).
+// TableName of model is still the first shard table, a query without Shard(key) reads and writes the first shard only.
+type Shard struct {
+	Table   string `yaml:"table"`
+	Pattern string `yaml:"pattern"`
+	Format  string `yaml:"format"`
+	Start   int    `yaml:"start"`
+	Count   int    `yaml:"count"`
+}
+
+var shardSuffix = regexp.MustCompile(`^(\D*)(\d+)$`)
+
+func (s Shard) validate() error {
+	switch {
+	case s.Table == "":
+		return errors.Errorf("invalid shard %+v: table is required", s)
+	case s.Count < 0:
+		return errors.Errorf("invalid shard %+v: count must be positive", s)
+	case s.Start < 0:
+		return errors.Errorf("invalid shard %+v: start must not be negative", s)
+	case s.Format != "" && (strings.Count(s.Format, "%") != 1 || strings.Contains(fmt.Sprintf(s.Format, 0), "%!")):
+		return errors.Errorf("invalid shard %+v: format must have one integer verb, Example: %s_%%02d", s, s.Table)
+	}
+	return tablePattern(s.pattern()).validate()
+}
+
+func (s Shard) pattern() string {
+	if s.Pattern != "" {
+		return s.Pattern
+	}
+	return "/^" + regexp.QuoteMeta(s.Table) + `_\d+$/`
+}
+
+// resolve fills the default format, start and count from shard tables which are sorted
+func (s Shard) resolve(tables []string) (Shard, error) {
+	if s.Count == 0 {
+		s.Count = len(tables)
+	}
+	_, name := splitTable(tables[0])
+	if s.Format == "" {
+		arr := shardSuffix.FindStringSubmatch(strings.TrimPrefix(name, s.Table))
+		if !strings.HasPrefix(name, s.Table) || arr == nil {
+			return s, errors.Errorf("invalid shard %+v: format is required since it cannot be derived from %s", s, name)
+		}
+		s.Format = s.Table + arr[1] + "%d"
+		if len(arr[2]) > 1 && strings.HasPrefix(arr[2], "0") {
+			s.Format = fmt.Sprintf("%s%s%%0%dd", s.Table, arr[1], len(arr[2]))
+		}
+	}
+	if s.Start == 0 {
+		// the first shard table is not a number of format if pattern matches other names, start is 0 then
+		fmt.Sscanf(name, s.Format, &s.Start)
+	}
+	return s, nil
+}
+
+// shardTable returns the logical table of shard table, a qualified table keeps its schema
+func shardTable(cfg *CmdGenParams, table string) (logical string, ok bool) {
+	for _, item := range *cfg.Shard {
+		if _, ok = matchTable([]string{item.pattern()}, table); ok {
+			if s, _ := splitTable(table); s != "" {
+				return s + "." + item.Table, true
+			}
+			return item.Table, true
+		}
+	}
+	return
+}
+
+// groupShards keeps the first shard table of every shard, other shard tables are excluded
+func groupShards(cfg *CmdGenParams, list []tableSelection) (rp []tableSelection, err error) {
+	for _, s := range *cfg.Shard {
+		members := make([]string, 0)
+		for _, item := range list {
+			if item.included && !item.association {
+				if _, ok := matchTable([]string{s.pattern()}, item.table); ok {
+					members = append(members, item.table)
+				}
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		sort.Strings(members)
+		var resolved Shard
+		resolved, err = s.resolve(members)
+		if err != nil {
+			return
+		}
+		for i, item := range list {
+			switch {
+			case item.table == members[0]:
+				list[i].reason = fmt.Sprintf("%s, shard %s of %d tables", item.reason, s.Table, len(members))
+				list[i].shard = &resolved
+			case item.included && !item.association && utils.Contains[string](members, item.table):
+				list[i].included = false
+				list[i].reason = fmt.Sprintf("shard %s is generated from %s", s.Table, members[0])
+			}
+		}
+	}
+	return list, nil
+}
+
+// genShards writes ShardTable of model and Shard of query for shard tables
+func genShards(cfg *CmdGenParams, gormDB *gorm.DB, list []tableSelection) (err error) {
+	for _, item := range list {
+		if item.shard == nil {
+			continue
+		}
+		model := modelName(cfg, gormDB, item.table)
+		data := map[string]interface{}{
+			"Header": cinchHeader,
+			"Model":  model,
+			"Query":  strings.ToLower(model[:1]) + model[1:],
+			"Recv":   strings.ToLower(model[:1]),
+			"Table":  item.shard.Table,
+			"Format": item.shard.Format,
+			"Start":  item.shard.Start,
+			"Count":  item.shard.Count,
+			"First":  fmt.Sprintf(item.shard.Format, item.shard.Start),
+			"Last":   fmt.Sprintf(item.shard.Format, item.shard.Start+item.shard.Count-1),
+		}
+		dir := modelDir(*cfg.OutPath, *cfg.ModelPkgName)
+		err = writeShard(shardModelTemplate, data, filepath.Join(dir, fileName(cfg, item.table)+"_shard.gen.go"))
+		if err != nil {
+			return
+		}
+		if *cfg.OnlyModel {
+			continue
+		}
+		err = writeShard(shardQueryTemplate, data, filepath.Join(*cfg.OutPath, fileName(cfg, item.table)+"_shard.gen.go"))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeShard(tmpl *template.Template, data map[string]interface{}, file string) (err error) {
+	var buf bytes.Buffer
+	data["Package"] = filepath.Base(filepath.Dir(file))
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return
+	}
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		err = fmt.Errorf("format shard of %s fail: %w", data["Table"], err)
+		return
+	}
+	return os.WriteFile(file, content, 0640)
+}
+
+var shardModelTemplate = template.Must(template.New("shardModel").Parse(`{{.Header}}
+
+package {{.Package}}
+
+import "fmt"
+
+// {{.Model}}ShardCount is the shard count of table {{.Table}}
+const {{.Model}}ShardCount = {{.Count}}
+
+// {{.Model}}ShardStart is the number of the first shard of table {{.Table}}
+const {{.Model}}ShardStart = {{.Start}}
+
+// ShardTable returns the shard table of key, {{.First}}...{{.Last}}.
+// NOTE: TableName is the first shard {{.First}}, use ShardTable or Shard of query, otherwise only {{.First}} is read and written.
+func (*{{.Model}}) ShardTable(key uint64) string {
+	return fmt.Sprintf("{{.Format}}", {{.Model}}ShardStart+key%{{.Model}}ShardCount)
+}
+`))
+
+var shardQueryTemplate = template.Must(template.New("shardQuery").Parse(`{{.Header}}
+
+package {{.Package}}
+
+import "fmt"
+
+// Shard returns the query of shard table of key, {{.First}}...{{.Last}}.
+// NOTE: the query without Shard reads and writes the first shard {{.First}} only.
+func ({{.Recv}} {{.Query}}) Shard(key uint64) *{{.Query}} {
+	return {{.Recv}}.Table(fmt.Sprintf("{{.Format}}", {{.Start}}+key%{{.Count}}))
+}
+`))
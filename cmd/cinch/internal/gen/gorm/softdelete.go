@@ -0,0 +1,168 @@
+package gorm
+
+import (
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// SoftDeleteTime uses gorm.DeletedAt, the column is a nullable datetime
+	SoftDeleteTime = "time"
+	// SoftDeleteUnix uses soft_delete.DeletedAt, the column is unix seconds
+	SoftDeleteUnix = "unix"
+	// SoftDeleteMilli uses soft_delete.DeletedAt with softDelete:milli, the column is unix milliseconds
+	SoftDeleteMilli = "milli"
+	// SoftDeleteNano uses soft_delete.DeletedAt with softDelete:nano, the column is unix nanoseconds
+	SoftDeleteNano = "nano"
+	// SoftDeleteFlag uses soft_delete.DeletedAt with softDelete:flag, the column is 0 or 1
+	SoftDeleteFlag = "flag"
+	// SoftDeleteNone disables soft delete of table
+	SoftDeleteNone = "none"
+
+	softDeleteImport = "gorm.io/plugin/soft_delete"
+)
+
+var softDeleteStrategies = []string{SoftDeleteTime, SoftDeleteUnix, SoftDeleteMilli, SoftDeleteNano, SoftDeleteFlag, SoftDeleteNone}
+
+// defaultSoftDelete is used unless gen.yml has a soft delete without table
+var defaultSoftDelete = SoftDelete{Column: "deleted_at", Strategy: SoftDeleteTime}
+
+// SoftDelete is the soft delete column of tables, consult[https://gorm.io/docs/delete.html#Soft-Delete], in gen.yml:
+//
+//	soft-delete:
+//	  - column: deleted_at
+//	    strategy: time
+//	  - table: user
+//	    column: deleted_ts
+//	    strategy: unix
+//	  - table: order
+//	    column: is_del
+//	    strategy: flag
+//
+// the item without table works for all tables, table item is prior to it, default column is deleted_at, default strategy is time.
+// strategy is time/unix/milli/nano/flag/none, except time they need gorm.io/plugin/soft_delete.
+type SoftDelete struct {
+	Table    string `yaml:"table"`
+	Column   string `yaml:"column"`
+	Strategy string `yaml:"strategy"`
+}
+
+// normalizeSoftDeletes fills the default column and strategy, then validates items
+func normalizeSoftDeletes(list []SoftDelete) error {
+	for i := range list {
+		if list[i].Column == "" {
+			list[i].Column = defaultSoftDelete.Column
+		}
+		if list[i].Strategy == "" {
+			list[i].Strategy = defaultSoftDelete.Strategy
+		}
+		if !utils.Contains[string](softDeleteStrategies, list[i].Strategy) {
+			return errors.Errorf("invalid soft delete %+v: strategy %q is not one of %s", list[i], list[i].Strategy, strings.Join(softDeleteStrategies, "/"))
+		}
+	}
+	return nil
+}
+
+// GoType is the model field type
+func (s SoftDelete) GoType() string {
+	if s.Strategy == SoftDeleteTime {
+		return "gorm.DeletedAt"
+	}
+	return "soft_delete.DeletedAt"
+}
+
+// Field is the model field name
+func (s SoftDelete) Field() string {
+	return schema.NamingStrategy{}.SchemaName(s.Column)
+}
+
+// Restored is the column value of a record which is not deleted
+func (s SoftDelete) Restored() string {
+	if s.Strategy == SoftDeleteTime {
+		return "nil"
+	}
+	return "0"
+}
+
+// gormTag is the softDelete value of gorm tag, empty for time and unix
+func (s SoftDelete) gormTag() string {
+	switch s.Strategy {
+	case SoftDeleteMilli, SoftDeleteNano, SoftDeleteFlag:
+		return s.Strategy
+	}
+	return ""
+}
+
+// tableSoftDeletes returns soft delete items of table, empty if soft delete is disabled
+func tableSoftDeletes(list []SoftDelete, table string) (rp []SoftDelete) {
+	global := make([]SoftDelete, 0)
+	for _, item := range list {
+		switch item.Table {
+		case table:
+			rp = append(rp, item)
+		case "":
+			global = append(global, item)
+		}
+	}
+	if len(rp) == 0 {
+		rp = global
+	}
+	if len(rp) == 0 {
+		rp = []SoftDelete{defaultSoftDelete}
+	}
+	enabled := make([]SoftDelete, 0, len(rp))
+	for _, item := range rp {
+		if item.Strategy != SoftDeleteNone {
+			enabled = append(enabled, item)
+		}
+	}
+	if len(enabled) < len(rp) {
+		return nil
+	}
+	return enabled
+}
+
+// softDeleteOpts returns the field type and gorm tag options of soft delete columns
+func softDeleteOpts(cfg *CmdGenParams, table string) (opts []gen.ModelOpt) {
+	for _, item := range tableSoftDeletes(*cfg.SoftDelete, table) {
+		opts = append(opts, gen.FieldType(item.Column, item.GoType()))
+		if v := item.gormTag(); v != "" {
+			opts = append(opts, gen.FieldGORMTag(item.Column, func(tag field.GormTag) field.GormTag {
+				tag.Set("softDelete", v)
+				return tag
+			}))
+		}
+	}
+	return
+}
+
+// softDeleteImports returns import path of soft delete plugin if any strategy needs it
+func softDeleteImports(cfg *CmdGenParams) (paths []string) {
+	for _, item := range *cfg.SoftDelete {
+		if item.Strategy != SoftDeleteTime && item.Strategy != SoftDeleteNone {
+			return []string{softDeleteImport}
+		}
+	}
+	return
+}
+
+// TableSoftDelete reads the soft delete column of table from gen.yml, soft-delete of the source which generates table is used,
+// source is required only if sources set it differently, ok is false if soft delete is not configured or disabled for table
+func TableSoftDelete(configPath, source, table string) (item SoftDelete, ok bool, err error) {
+	return tableSource(configPath, source, table, func(cfg *CmdGenParams) (SoftDelete, bool) {
+		// the default soft delete works only if table has the column, which is unknown here
+		if len(*cfg.SoftDelete) == 0 {
+			return SoftDelete{}, false
+		}
+		rp := tableSoftDeletes(*cfg.SoftDelete, table)
+		if len(rp) == 0 {
+			return SoftDelete{}, false
+		}
+		return rp[0], true
+	})
+}
@@ -0,0 +1,179 @@
+package gorm
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Source is a named data source, the keys of gen block can be overwritten, in gen.yml:
+//
+//	gen:
+//	  field-with-enum: true
+//	  sources:
+//	    - name: primary
+//	      db: mysql
+//	      dsn: root:root@tcp(127.0.0.1:3306)/app?parseTime=True
+//	    - name: analytics
+//	      db: clickhouse
+//	      dsn: clickhouse://127.0.0.1:9000/analytics
+//	      tables: [event, page_view]
+//	      out-path: internal/data/analytics/query
+//	      model-pkg-name: internal/data/analytics/model
+//
+// every source generates its own query and model package.
+type Source struct {
+	Name         string `yaml:"name"`
+	CmdGenParams `yaml:",inline" mapstructure:",squash"`
+}
+
+// sourceConfigs returns the configs of sources selected by names, cfg itself if there is no source
+func sourceConfigs(cfg *CmdGenParams, configPath string, names []string) (list []*CmdGenParams, err error) {
+	if len(*cfg.Sources) == 0 {
+		if len(names) > 0 {
+			err = errors.Errorf("--source %s is set but gen.yml has no sources", strings.Join(names, ","))
+			return
+		}
+		list = append(list, cfg)
+		return
+	}
+	var node *yaml.Node
+	if configPath != "" {
+		node, err = readConfigNode(configPath, "gen", "sources")
+		if err != nil {
+			return
+		}
+	}
+	all := make([]string, 0, len(*cfg.Sources))
+	outPaths := make(map[string]string)
+	for i, s := range *cfg.Sources {
+		if s.Name == "" {
+			err = errors.Errorf("invalid source %d: name is required", i+1)
+			return
+		}
+		if utils.Contains[string](all, s.Name) {
+			err = errors.Errorf("invalid source %s: duplicate name", s.Name)
+			return
+		}
+		all = append(all, s.Name)
+		item := cfg.merge(s.CmdGenParams)
+		item.source = s.Name
+		// association is not decoded by viper, the source one overwrites the gen one
+		if node != nil && node.Kind == yaml.SequenceNode && i < len(node.Content) {
+			if n := mappingValue(node.Content[i], "association"); n != nil {
+				var associations []Association
+				associations, err = associationsFromNode(n, configPath)
+				if err != nil {
+					return
+				}
+				item.Association = &associations
+			}
+		}
+		err = item.validate()
+		if err != nil {
+			err = errors.Wrapf(err, "invalid source %s", s.Name)
+			return
+		}
+		for _, p := range []string{*item.OutPath, modelDir(*item.OutPath, *item.ModelPkgName)} {
+			p = filepath.Clean(p)
+			if other, ok := outPaths[p]; ok {
+				err = errors.Errorf("invalid source %s: output path %s is used by source %s", s.Name, p, other)
+				return
+			}
+			outPaths[p] = s.Name
+		}
+		if len(names) == 0 || utils.Contains[string](names, s.Name) {
+			list = append(list, item)
+		}
+	}
+	for _, name := range names {
+		if !utils.Contains[string](all, name) {
+			err = errors.Errorf("unknown source %s, support %s", name, strings.Join(all, "/"))
+			return
+		}
+	}
+	return
+}
+
+// merge returns a copy of cfg overwritten by the non-nil fields of s,
+// every field is copied so that changes of one source do not affect others
+func (cfg CmdGenParams) merge(s CmdGenParams) *CmdGenParams {
+	var merged CmdGenParams
+	dst := reflect.ValueOf(&merged).Elem()
+	base := reflect.ValueOf(cfg)
+	override := reflect.ValueOf(s)
+	for i := 0; i < dst.NumField(); i++ {
+		if !dst.Type().Field(i).IsExported() {
+			continue
+		}
+		v := override.Field(i)
+		if v.IsNil() {
+			v = base.Field(i)
+		}
+		if v.IsNil() {
+			continue
+		}
+		p := reflect.New(v.Type().Elem())
+		if v.Elem().Kind() == reflect.Slice {
+			p.Elem().Set(reflect.AppendSlice(reflect.MakeSlice(v.Elem().Type(), 0, v.Elem().Len()), v.Elem()))
+		} else {
+			p.Elem().Set(v.Elem())
+		}
+		dst.Field(i).Set(p)
+	}
+	merged.Sources = &[]Source{}
+	merged.configPath = cfg.configPath
+	return &merged
+}
+
+// tableConfigs returns the configs of sources which may generate table, source selects one of them,
+// a source whose tables do not match table or whose exclude matches table does not generate it
+func tableConfigs(configPath, source, table string) (list []*CmdGenParams, err error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0)
+	if source != "" {
+		names = append(names, source)
+	}
+	sources, err := sourceConfigs(cfg, configPath, names)
+	if err != nil || len(sources) == 1 {
+		return sources, err
+	}
+	for _, item := range sources {
+		if len(*item.Tables) > 0 {
+			if _, ok := matchTable(*item.Tables, table); !ok {
+				continue
+			}
+		}
+		if isExcluded(*item.Exclude, table) {
+			continue
+		}
+		list = append(list, item)
+	}
+	return
+}
+
+// tableSource returns the only setting of table in configs of sources, it is an error if sources set it differently
+func tableSource[T any](configPath, source, table string, get func(cfg *CmdGenParams) (T, bool)) (item T, ok bool, err error) {
+	list, err := tableConfigs(configPath, source, table)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(list))
+	for i, cfg := range list {
+		v, found := get(cfg)
+		if i > 0 && (found != ok || !reflect.DeepEqual(v, item)) {
+			err = errors.Errorf("table %s is set differently by sources %s, set --source", table, strings.Join(append(names, cfg.source), ","))
+			return
+		}
+		item, ok = v, found
+		names = append(names, cfg.source)
+	}
+	return
+}
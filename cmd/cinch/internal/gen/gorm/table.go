@@ -0,0 +1,205 @@
+package gorm
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// tablePattern is an item of tables or exclude, it is an exact name, a glob or a regex enclosed by slashes, in gen.yml:
+//
+//	tables:
+//	  - user
+//	  - sys_*
+//	  - /^tmp_\d+$/
+//	exclude:
+//	  - "*_bak"
+//
+// an unqualified pattern matches the table of every schema.
+type tablePattern string
+
+// isRegex reports whether p is a regex enclosed by slashes
+func (p tablePattern) isRegex() bool {
+	return len(p) > 2 && strings.HasPrefix(string(p), "/") && strings.HasSuffix(string(p), "/")
+}
+
+// isLiteral reports whether p is an exact table name
+func (p tablePattern) isLiteral() bool {
+	return !p.isRegex() && !strings.ContainsAny(string(p), "*?[")
+}
+
+func (p tablePattern) validate() (err error) {
+	if p.isRegex() {
+		_, err = regexp.Compile(string(p[1 : len(p)-1]))
+	} else if !p.isLiteral() {
+		_, err = path.Match(string(p), "")
+	}
+	if err != nil {
+		err = errors.Errorf("invalid table pattern %s: %v", p, err)
+	}
+	return
+}
+
+// match reports whether name matches p, p is validated already
+func (p tablePattern) match(name string) bool {
+	switch {
+	case p.isRegex():
+		re, err := regexp.Compile(string(p[1 : len(p)-1]))
+		return err == nil && re.MatchString(name)
+	case p.isLiteral():
+		return string(p) == name
+	}
+	ok, _ := path.Match(string(p), name)
+	return ok
+}
+
+// validateTablePatterns validates every pattern of list
+func validateTablePatterns(list []string) (err error) {
+	for _, item := range list {
+		err = tablePattern(item).validate()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// literalTables reports whether every pattern of list is an exact table name
+func literalTables(list []string) bool {
+	for _, item := range list {
+		if !tablePattern(item).isLiteral() {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTable returns the first pattern of list which matches table, a qualified table is also matched by its name
+func matchTable(list []string, table string) (pattern string, ok bool) {
+	_, name := splitTable(table)
+	for _, item := range list {
+		p := tablePattern(item)
+		if p.match(table) || (name != table && !strings.Contains(item, ".") && p.match(name)) {
+			return item, true
+		}
+	}
+	return
+}
+
+// isExcluded reports whether table matches exclude
+func isExcluded(exclude []string, table string) bool {
+	_, ok := matchTable(exclude, table)
+	return ok
+}
+
+// tableSelection is a table considered by cfg and the reason why it is included or excluded
+type tableSelection struct {
+	table    string
+	included bool
+	reason   string
+	// association tables are generated with relation options
+	association bool
+	// shard is set if table is the first shard table of it
+	shard *Shard
+}
+
+// selectTables returns the tables of database and associations, then marks them by tables, exclude and association
+func selectTables(cfg *CmdGenParams, gormDB *gorm.DB) (list []tableSelection, err error) {
+	candidates, err := listTables(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	associated := make([]string, 0, len(*cfg.Association)*2)
+	for _, item := range *cfg.Association {
+		if !cfg.inPass(item.Table) {
+			continue
+		}
+		for _, table := range []string{item.Table, item.Relation} {
+			if !utils.Contains[string](associated, table) {
+				associated = append(associated, table)
+			}
+		}
+	}
+	for _, table := range candidates {
+		item := tableSelection{table: table}
+		if utils.Contains[string](associated, table) {
+			list = append(list, associatedTable(table))
+			continue
+		}
+		if len(*cfg.Tables) > 0 {
+			pattern, ok := matchTable(*cfg.Tables, table)
+			if !ok {
+				item.reason = "not match tables"
+				list = append(list, item)
+				continue
+			}
+			item.reason = fmt.Sprintf("match tables %s", pattern)
+		} else if utils.Contains[string](cfg.views, table) {
+			item.reason = "all views"
+		} else {
+			item.reason = "all tables"
+		}
+		if pattern, ok := matchTable(*cfg.Exclude, table); ok {
+			item.reason = fmt.Sprintf("match exclude %s", pattern)
+			list = append(list, item)
+			continue
+		}
+		item.included = true
+		list = append(list, item)
+	}
+	// association tables are generated even if they are not listed
+	for _, table := range associated {
+		if !utils.Contains[string](candidates, table) {
+			list = append(list, associatedTable(table))
+		}
+	}
+	return groupShards(cfg, list)
+}
+
+func associatedTable(table string) tableSelection {
+	return tableSelection{
+		table:       table,
+		included:    true,
+		reason:      "association",
+		association: true,
+	}
+}
+
+// listModels prints the tables would be generated by cfg and why
+func listModels(cfg *CmdGenParams) (err error) {
+	gormDB, closeDB, err := newDB(cfg)
+	if err != nil {
+		return
+	}
+	defer closeDB()
+
+	err = withViews(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	err = withForeignKeyAssociations(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	list, err := selectTables(cfg, gormDB)
+	if err != nil {
+		return
+	}
+	count := 0
+	for _, item := range list {
+		if item.included {
+			count++
+			fmt.Printf("%s %s (%s)\n", color.GreenString("+"), item.table, item.reason)
+		} else {
+			fmt.Printf("%s %s (%s)\n", color.RedString("-"), item.table, item.reason)
+		}
+	}
+	fmt.Printf("%d of %d tables would be generated\n", count, len(list))
+	return
+}
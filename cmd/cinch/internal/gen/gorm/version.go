@@ -0,0 +1,87 @@
+package gorm
+
+import (
+	"gorm.io/gen"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// defaultVersionColumn is used if column is empty
+	defaultVersionColumn = "version"
+	// versionDisabled as column disables optimistic lock of table
+	versionDisabled = "-"
+
+	versionImport = "gorm.io/plugin/optimisticlock"
+)
+
+// Version is the optimistic lock column of tables, consult[https://github.com/go-gorm/optimisticlock], in gen.yml:
+//
+//	optimistic-lock:
+//	  - column: version
+//	  - table: order
+//	    column: revision
+//	  - table: log
+//	    column: "-"
+//
+// the item without table works for all tables, table item is prior to it, "-" disables optimistic lock of table.
+// the column is generated as optimisticlock.Version, it needs gorm.io/plugin/optimisticlock.
+type Version struct {
+	Table  string `yaml:"table"`
+	Column string `yaml:"column"`
+}
+
+// Field is the model field name
+func (v Version) Field() string {
+	return schema.NamingStrategy{}.SchemaName(v.Column)
+}
+
+// normalizeVersions fills the default column
+func normalizeVersions(list []Version) {
+	for i := range list {
+		if list[i].Column == "" {
+			list[i].Column = defaultVersionColumn
+		}
+	}
+}
+
+// tableVersion returns the version column of table, ok is false if table has no optimistic lock
+func tableVersion(list []Version, table string) (item Version, ok bool) {
+	for _, v := range list {
+		if v.Table == table {
+			return v, v.Column != versionDisabled
+		}
+	}
+	for _, v := range list {
+		if v.Table == "" {
+			return v, v.Column != versionDisabled
+		}
+	}
+	return
+}
+
+// versionOpts returns the field type options of version column,
+// field.Int64 is kept in query so that the version predicate is type safe
+func versionOpts(cfg *CmdGenParams, table string) (opts []gen.ModelOpt) {
+	if v, ok := tableVersion(*cfg.OptimisticLock, table); ok {
+		opts = append(opts, gen.FieldType(v.Column, "optimisticlock.Version"), gen.FieldGenType(v.Column, "Int64"))
+	}
+	return
+}
+
+// versionImports returns import path of optimistic lock plugin if any table uses it
+func versionImports(cfg *CmdGenParams) (paths []string) {
+	for _, v := range *cfg.OptimisticLock {
+		if v.Column != versionDisabled {
+			return []string{versionImport}
+		}
+	}
+	return
+}
+
+// TableVersion reads the optimistic lock column of table from gen.yml, optimistic-lock of the source which generates table is used,
+// source is required only if sources set it differently, ok is false if table has no optimistic lock
+func TableVersion(configPath, source, table string) (item Version, ok bool, err error) {
+	return tableSource(configPath, source, table, func(cfg *CmdGenParams) (Version, bool) {
+		return tableVersion(*cfg.OptimisticLock, table)
+	})
+}
@@ -0,0 +1,191 @@
+package gorm
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+)
+
+// viewSQL lists views of the current database or schema
+var viewSQL = map[string]string{
+	"mysql":      "SELECT TABLE_NAME FROM information_schema.VIEWS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME",
+	"postgres":   "SELECT table_name FROM information_schema.views WHERE table_schema = CURRENT_SCHEMA() ORDER BY table_name",
+	"sqlserver":  "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.VIEWS WHERE TABLE_SCHEMA = SCHEMA_NAME() ORDER BY TABLE_NAME",
+	"sqlite":     "SELECT name FROM sqlite_master WHERE type = 'view' ORDER BY name",
+	"clickhouse": "SELECT name FROM system.tables WHERE database = currentDatabase() AND engine IN ('View', 'MaterializedView') ORDER BY name",
+}
+
+// materializedViewSQL lists materialized views which are not in viewSQL
+var materializedViewSQL = map[string]string{
+	"postgres": "SELECT matviewname FROM pg_matviews WHERE schemaname = CURRENT_SCHEMA() ORDER BY matviewname",
+}
+
+// readOnlyMethods are the write methods of query struct, they are removed or shadowed for views
+var readOnlyMethods = []string{
+	"Create",
+	"CreateInBatches",
+	"Save",
+	"FirstOrCreate",
+	"Update",
+	"UpdateSimple",
+	"Updates",
+	"UpdateColumn",
+	"UpdateColumnSimple",
+	"UpdateColumns",
+	"UpdateFrom",
+	"Delete",
+}
+
+// withViews lists views and materialized views of search path into cfg.views if cfg.WithView is set
+func withViews(cfg *CmdGenParams, gormDB *gorm.DB) (err error) {
+	cfg.views = nil
+	if !*cfg.WithView {
+		return
+	}
+	name := gormDB.Dialector.Name()
+	query, ok := viewSQL[name]
+	if !ok {
+		return errors.Errorf("view is not supported by %s", name)
+	}
+	var views []string
+	err = gormDB.Raw(query).Scan(&views).Error
+	if err != nil {
+		return fmt.Errorf("get views fail: %w", err)
+	}
+	var materialized []string
+	if query, ok = materializedViewSQL[name]; ok {
+		err = gormDB.Raw(query).Scan(&materialized).Error
+		if err != nil {
+			return fmt.Errorf("get materialized views fail: %w", err)
+		}
+	}
+	cfg.views = append(views, materialized...)
+	if len(materialized) > 0 {
+		gormDB.Dialector = viewDialector{Dialector: gormDB.Dialector, materialized: materialized}
+	}
+	return
+}
+
+// viewDialector reads column types of materialized views from query result, they are not in information_schema.columns
+type viewDialector struct {
+	gorm.Dialector
+	materialized []string
+}
+
+func (d viewDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return viewMigrator{Migrator: d.Dialector.Migrator(db), db: db, materialized: d.materialized}
+}
+
+type viewMigrator struct {
+	gorm.Migrator
+	db           *gorm.DB
+	materialized []string
+}
+
+func (m viewMigrator) ColumnTypes(value interface{}) (list []gorm.ColumnType, err error) {
+	table, ok := value.(string)
+	if !ok || !utils.Contains[string](m.materialized, table) {
+		return m.Migrator.ColumnTypes(value)
+	}
+	rows, err := m.db.Session(&gorm.Session{}).Table(table).Limit(1).Rows()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+	for _, col := range cols {
+		list = append(list, migrator.ColumnType{
+			SQLColumnType: col,
+			NameValue:     sql.NullString{String: col.Name(), Valid: true},
+		})
+	}
+	return
+}
+
+// readOnlyViews removes write methods from the query files of views, and unit test files of them since they create records
+func readOnlyViews(cfg *CmdGenParams, tables []string) (err error) {
+	for _, table := range tables {
+		if !utils.Contains[string](cfg.views, table) {
+			continue
+		}
+		name := filepath.Join(*cfg.OutPath, fileName(cfg, table))
+		err = readOnlyQuery(name + ".gen.go")
+		if err != nil {
+			return fmt.Errorf("make query of view %s read-only fail: %w", table, err)
+		}
+		err = os.Remove(name + ".gen_test.go")
+		if err != nil && !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+	}
+	return
+}
+
+// readOnlyQuery removes typed write methods of the xxxDo struct in file,
+// the ones promoted from gen.DO are shadowed by fields of the same name, so calling them does not compile
+func readOnlyQuery(file string) (err error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return
+	}
+	var doName string
+	decls := make([]ast.Decl, 0, len(f.Decls))
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && len(fn.Recv.List) == 1 {
+			recv := fn.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if ident, ok := recv.(*ast.Ident); ok && strings.HasSuffix(ident.Name, "Do") && utils.Contains[string](readOnlyMethods, fn.Name.Name) {
+				doName = ident.Name
+				continue
+			}
+		}
+		decls = append(decls, decl)
+	}
+	if doName == "" {
+		return errors.Errorf("query struct is not found in %s", file)
+	}
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+	f.Decls = decls
+	f.Comments = cmap.Filter(f).Comments()
+
+	var buf bytes.Buffer
+	err = printer.Fprint(&buf, fset, f)
+	if err != nil {
+		return
+	}
+	old := fmt.Sprintf("type %s struct{ gen.DO }", doName)
+	if !bytes.Contains(buf.Bytes(), []byte(old)) {
+		return errors.Errorf("%s is not found in %s", old, file)
+	}
+	src := bytes.Replace(buf.Bytes(), []byte(old), []byte(fmt.Sprintf(`type %s struct {
+	gen.DO
+
+	// the table is a view, write methods of gen.DO are shadowed
+	%s struct{}
+}`, doName, strings.Join(readOnlyMethods, ", "))), 1)
+	src, err = format.Source(src)
+	if err != nil {
+		return
+	}
+	return os.WriteFile(file, src, 0o640)
+}
@@ -0,0 +1,471 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var migrateAuto = &cobra.Command{
+	Use:   "auto",
+	Short: "Create a new database migration by comparing gorm models with the database.",
+	Long:  "Create a new database migration by comparing gorm models of gen.yml model-pkg-name with the database, missing tables, columns and indexes are created, columns of type tag or not null are modified. Example: cinch gen migrate auto add_user_email",
+	Run:   MigrateAutoRun,
+}
+
+var autoTemplateContent = `
+-- +migrate Up
+{{- range .Up}}
+{{.}}
+{{- end}}
+
+-- +migrate Down
+{{- range .Down}}
+{{.}}
+{{- end}}
+`
+var autoTpl *template.Template
+
+// defaultKeyword matches the default values which are not quoted
+var defaultKeyword = regexp.MustCompile(`(?i)^(-?\d+(\.\d+)?|null|true|false|current_timestamp(\(\d*\))?|now\(\)|'.*')$`)
+
+// goTypes maps go type of model field to column type of mysql, postgres and sqlite3, string is mapped by size
+var goTypes = map[string][3]string{
+	"bool":                   {"tinyint(1)", "boolean", "numeric"},
+	"int8":                   {"tinyint", "smallint", "integer"},
+	"int16":                  {"smallint", "smallint", "integer"},
+	"int32":                  {"int", "integer", "integer"},
+	"int":                    {"bigint", "bigint", "integer"},
+	"int64":                  {"bigint", "bigint", "integer"},
+	"uint8":                  {"tinyint unsigned", "smallint", "integer"},
+	"uint16":                 {"smallint unsigned", "integer", "integer"},
+	"uint32":                 {"int unsigned", "bigint", "integer"},
+	"uint":                   {"bigint unsigned", "bigint", "integer"},
+	"uint64":                 {"bigint unsigned", "bigint", "integer"},
+	"float32":                {"float", "real", "real"},
+	"float64":                {"double", "double precision", "real"},
+	"[]byte":                 {"longblob", "bytea", "blob"},
+	"time.Time":              {"datetime(3)", "timestamptz", "datetime"},
+	"gorm.DeletedAt":         {"datetime(3)", "timestamptz", "datetime"},
+	"carbon.DateTime":        {"datetime(3)", "timestamptz", "datetime"},
+	"carbon.Date":            {"date", "date", "date"},
+	"sql.NullBool":           {"tinyint(1)", "boolean", "numeric"},
+	"sql.NullInt32":          {"int", "integer", "integer"},
+	"sql.NullInt64":          {"bigint", "bigint", "integer"},
+	"sql.NullFloat64":        {"double", "double precision", "real"},
+	"sql.NullTime":           {"datetime(3)", "timestamptz", "datetime"},
+	"optimisticlock.Version": {"bigint", "bigint", "integer"},
+	"soft_delete.DeletedAt":  {"bigint unsigned", "bigint", "integer"},
+	"datatypes.JSON":         {"json", "jsonb", "text"},
+	"json.RawMessage":        {"json", "jsonb", "text"},
+	"decimal.Decimal":        {"decimal(20,6)", "numeric(20,6)", "numeric"},
+	"decimal.NullDecimal":    {"decimal(20,6)", "numeric(20,6)", "numeric"},
+}
+
+// serializerTypes maps serializer tag of model field to column type of mysql, postgres and sqlite3
+var serializerTypes = map[string][3]string{
+	"json":     {"json", "jsonb", "text"},
+	"gob":      {"longblob", "bytea", "blob"},
+	"unixtime": {"bigint", "bigint", "integer"},
+}
+
+// unknownTypeError is returned by columnType if the column type cannot be mapped,
+// the column is written as a TODO note instead of a statement
+type unknownTypeError struct {
+	table  string
+	column modelColumn
+}
+
+func (e unknownTypeError) Error() string {
+	return fmt.Sprintf("unknown column type of %s.%s (%s), add type to its gorm tag or generate models with --fieldWithTypeTag", e.table, e.column.name, e.column.goType)
+}
+
+// note returns the TODO comment of the column
+func (e unknownTypeError) note() string {
+	return fmt.Sprintf("-- TODO: %s", e.Error())
+}
+
+// stringTypes are the go types mapped like string
+var stringTypes = []string{"string", "sql.NullString"}
+
+func init() {
+	autoTpl = template.Must(template.New("auto_migration").Parse(autoTemplateContent))
+	migrateAuto.Flags().StringP("model", "m", "", "Model directory, default is model-pkg-name of gen in config file.")
+	migrateAuto.Flags().StringP("source", "s", "", "Source name of gen in config file, required if gen has sources.")
+	migrateAuto.Flags().BoolP("dryrun", "d", false, "Don't create migration file, just print it.")
+}
+
+func MigrateAutoRun(cmd *cobra.Command, args []string) {
+	name := cmd.Flags().Arg(0)
+	if name == "" {
+		panic("Please provide a name for the migration.")
+	}
+	dir, _ := cmd.Flags().GetString("model")
+	source, _ := cmd.Flags().GetString("source")
+	dryrun, _ := cmd.Flags().GetBool("dryrun")
+	ConfigFlags(cmd)
+	env, err := GetEnvironment()
+	if err != nil {
+		panic(err)
+	}
+	if dir == "" {
+		dir, err = modelDir(source)
+		if err != nil {
+			panic(err)
+		}
+	}
+	models, err := parseModels(dir)
+	if err != nil {
+		panic(err)
+	}
+	if len(models) == 0 {
+		panic(fmt.Sprintf("No model found in %s.", dir))
+	}
+
+	gormDB, closeDB, err := openEnvironment(env)
+	if err != nil {
+		panic(err)
+	}
+	defer closeDB()
+	live, err := inspectSchema(gormDB, env)
+	if err != nil {
+		panic(err)
+	}
+	up, down, err := autoMigration(gormDB, env.Dialect, models, live)
+	if err != nil {
+		panic(err)
+	}
+	if !hasStatement(up) {
+		if len(up) == 0 {
+			fmt.Println(fmt.Sprintf("Models in %s are up to date, nothing to do.", dir))
+			return
+		}
+		// up only has notes which need to be done by hand
+		for _, line := range up {
+			fmt.Println(line)
+		}
+		fmt.Println(fmt.Sprintf("No statement can be generated for models in %s, do the above by hand.", dir))
+		return
+	}
+
+	data := map[string][]string{"Up": up, "Down": down}
+	if dryrun {
+		if err = autoTpl.Execute(os.Stdout, data); err != nil {
+			panic(err)
+		}
+		return
+	}
+	fileName := fmt.Sprintf("%s-%s.sql", time.Now().Format("20060102150405"), strings.TrimSpace(name))
+	pathName := path.Join(env.Dir, fileName)
+	f, err := os.Create(pathName)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := autoTpl.Execute(f, data); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(fmt.Sprintf("Created migration %s", pathName))
+}
+
+// autoMigration returns the up and down statements which make live same as models,
+// columns and tables not in models are only reported as comments since dropping them may lose data
+func autoMigration(gormDB *gorm.DB, dialect string, models []modelTable, live *dbSchema) (up, down []string, err error) {
+	d := ddl{dialect: dialect}
+	var stmts, undo []string
+	for _, m := range models {
+		table := live.tables[m.name]
+		if table == nil {
+			// views are not listed as tables
+			if cols, e := gormDB.Migrator().ColumnTypes(m.name); e == nil && len(cols) > 0 {
+				continue
+			}
+			stmts, undo, err = d.createTable(m)
+			if err != nil {
+				return
+			}
+			up = append(up, stmts...)
+			down = append(undo, down...)
+			continue
+		}
+
+		liveCols := make(map[string]dbColumn)
+		for _, col := range table.columns {
+			liveCols[col.name] = col
+		}
+		modelCols := make(map[string]bool)
+		for _, col := range m.columns {
+			modelCols[col.name] = true
+			old, ok := liveCols[col.name]
+			if !ok {
+				stmts, undo, err = d.addColumn(m.name, col)
+			} else {
+				stmts, undo, err = d.modifyColumn(m.name, col, old)
+			}
+			if err != nil {
+				return
+			}
+			up = append(up, stmts...)
+			down = append(undo, down...)
+		}
+		for _, col := range table.columns {
+			if !modelCols[col.name] {
+				up = append(up, fmt.Sprintf("-- column %s.%s is not in model %s, drop it by hand if it is intended", m.name, col.name, m.model))
+			}
+		}
+
+		liveIndexes := make(map[string]bool)
+		for _, idx := range table.indexes {
+			liveIndexes[idx.name] = true
+		}
+		for _, idx := range m.indexes {
+			if !liveIndexes[idx.name] {
+				up = append(up, d.createIndex(m.name, idx))
+				down = append([]string{d.dropIndex(m.name, idx)}, down...)
+			}
+		}
+	}
+	return
+}
+
+// hasStatement returns true if lines have any statement, notes are written as comments
+func hasStatement(lines []string) bool {
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// ddl writes statements of dialect
+type ddl struct {
+	dialect string
+}
+
+func (d ddl) quote(name string) string {
+	if d.dialect == "mysql" {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+func (d ddl) literal(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// columnType returns the type tag of col, or the type mapped from go type
+func (d ddl) columnType(table string, col modelColumn) (string, error) {
+	if col.typ != "" {
+		return col.typ, nil
+	}
+	i := map[string]int{"mysql": 0, "postgres": 1, "sqlite3": 2}[d.dialect]
+	if types, ok := serializerTypes[col.serializer]; ok {
+		return types[i], nil
+	}
+	for _, item := range stringTypes {
+		if col.goType == item {
+			switch {
+			case col.size > 0 && d.dialect != "sqlite3":
+				return fmt.Sprintf("varchar(%d)", col.size), nil
+			case d.dialect == "mysql":
+				return "longtext", nil
+			}
+			return "text", nil
+		}
+	}
+	if types, ok := goTypes[col.goType]; ok {
+		return types[i], nil
+	}
+	return "", unknownTypeError{table: table, column: col}
+}
+
+// column returns the column definition of col, primary key is defined by table except sqlite autoincrement
+func (d ddl) column(table string, col modelColumn) (string, error) {
+	typ, err := d.columnType(table, col)
+	if err != nil {
+		return "", err
+	}
+	def := d.quote(col.name) + " " + typ
+	if d.dialect == "sqlite3" && col.autoIncrement && col.primaryKey {
+		return def + " PRIMARY KEY AUTOINCREMENT", nil
+	}
+	if col.notNull {
+		def += " NOT NULL"
+	}
+	if col.def != nil {
+		def += " DEFAULT " + d.defaultValue(*col.def)
+	}
+	if col.autoIncrement {
+		switch d.dialect {
+		case "mysql":
+			def += " AUTO_INCREMENT"
+		case "postgres":
+			def += " GENERATED BY DEFAULT AS IDENTITY"
+		}
+	}
+	if col.comment != "" && d.dialect == "mysql" {
+		def += " COMMENT " + d.literal(col.comment)
+	}
+	return def, nil
+}
+
+func (d ddl) defaultValue(v string) string {
+	if defaultKeyword.MatchString(v) || strings.Contains(v, "(") {
+		return v
+	}
+	return d.literal(v)
+}
+
+// comments returns the column comments of postgres, others have comment in column definition
+func (d ddl) comments(table string, cols ...modelColumn) (list []string) {
+	if d.dialect != "postgres" {
+		return
+	}
+	for _, col := range cols {
+		if col.comment != "" {
+			list = append(list, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", d.quote(table), d.quote(col.name), d.literal(col.comment)))
+		}
+	}
+	return
+}
+
+func (d ddl) createTable(m modelTable) (up, down []string, err error) {
+	lines := make([]string, 0, len(m.columns)+1)
+	pk := make([]string, 0)
+	var notes []string
+	cols := make([]modelColumn, 0, len(m.columns))
+	for _, col := range m.columns {
+		var def string
+		def, err = d.column(m.name, col)
+		if e, ok := err.(unknownTypeError); ok {
+			notes = append(notes, e.note())
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		lines = append(lines, "  "+def)
+		cols = append(cols, col)
+		if col.primaryKey && !strings.Contains(def, "PRIMARY KEY") {
+			pk = append(pk, d.quote(col.name))
+		}
+	}
+	if len(pk) > 0 {
+		lines = append(lines, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(pk, ", ")))
+	}
+	stmt := fmt.Sprintf("CREATE TABLE %s\n(\n%s\n)", d.quote(m.name), strings.Join(lines, ",\n"))
+	if d.dialect == "mysql" {
+		stmt += " ENGINE = InnoDB"
+	}
+	up = append(up, notes...)
+	up = append(up, stmt+";")
+	up = append(up, d.comments(m.name, cols...)...)
+	for _, idx := range m.indexes {
+		up = append(up, d.createIndex(m.name, idx))
+	}
+	down = append(down, fmt.Sprintf("DROP TABLE %s;", d.quote(m.name)))
+	return
+}
+
+func (d ddl) addColumn(table string, col modelColumn) (up, down []string, err error) {
+	def, err := d.column(table, col)
+	if e, ok := err.(unknownTypeError); ok {
+		up = append(up, e.note())
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	up = append(up, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", d.quote(table), def))
+	up = append(up, d.comments(table, col)...)
+	down = append(down, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", d.quote(table), d.quote(col.name)))
+	return
+}
+
+// modifyColumn changes the type of col if it has type tag, and sets not null if it has not null tag,
+// a column is never changed to nullable since a model without not null tag does not state it, primary key is not changed
+func (d ddl) modifyColumn(table string, col modelColumn, old dbColumn) (up, down []string, err error) {
+	typeChanged := col.typ != "" && normalizeType(col.typ) != normalizeType(old.typ)
+	nullChanged := !col.primaryKey && col.notNull && old.nullable
+	if !typeChanged && !nullChanged {
+		return
+	}
+	typ := old.typ
+	if col.typ != "" {
+		typ = col.typ
+	}
+	switch d.dialect {
+	case "mysql":
+		// MODIFY COLUMN redefines the whole column, keep not null, default and comment of live column which model does not state
+		col.typ = typ
+		col.notNull = col.notNull || !old.nullable
+		if col.def == nil {
+			col.def = old.def
+		}
+		if col.comment == "" {
+			col.comment = old.comment
+		}
+		var def string
+		def, err = d.column(table, col)
+		if err != nil {
+			return
+		}
+		prev := modelColumn{name: old.name, typ: old.typ, notNull: !old.nullable, def: old.def, comment: old.comment}
+		prevDef, _ := d.column(table, prev)
+		up = append(up, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", d.quote(table), def))
+		down = append(down, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", d.quote(table), prevDef))
+	case "postgres":
+		name := d.quote(col.name)
+		if typeChanged {
+			up = append(up, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", d.quote(table), name, typ))
+			down = append(down, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", d.quote(table), name, old.typ))
+		}
+		if nullChanged {
+			up = append(up, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", d.quote(table), name))
+			down = append([]string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;", d.quote(table), name)}, down...)
+		}
+	default:
+		null := "NULL"
+		if col.notNull || !old.nullable {
+			null = "NOT NULL"
+		}
+		up = append(up, fmt.Sprintf("-- sqlite3 cannot alter column %s.%s to %s %s, rebuild the table by hand", table, col.name, typ, null))
+	}
+	return
+}
+
+func (d ddl) createIndex(table string, idx dbIndex) string {
+	cols := make([]string, 0, len(idx.columns))
+	for _, col := range idx.columns {
+		cols = append(cols, d.quote(col))
+	}
+	unique := ""
+	if idx.unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", unique, d.quote(idx.name), d.quote(table), strings.Join(cols, ", "))
+}
+
+func (d ddl) dropIndex(table string, idx dbIndex) string {
+	if d.dialect == "mysql" {
+		return fmt.Sprintf("DROP INDEX %s ON %s;", d.quote(idx.name), d.quote(table))
+	}
+	return fmt.Sprintf("DROP INDEX %s;", d.quote(idx.name))
+}
+
+// normalizeType makes the type tag comparable with the type of database
+func normalizeType(typ string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(strings.ToLower(typ), ", ", ",")), " ")
+}
@@ -1,55 +1,54 @@
 package migrate
 
 import (
+	"context"
+	"errors"
 	"fmt"
+
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
 	migrate "github.com/rubenv/sql-migrate"
 )
 
-func ApplyMigrations(dir migrate.MigrationDirection, dryrun bool, limit int, version int64) error {
+func ApplyMigrations(dir migrate.MigrationDirection, dryrun bool, limit int, version int64, allowModified bool) error {
 	env, err := GetEnvironment()
 	if err != nil {
 		return fmt.Errorf("could not parse config: %s", err)
 	}
 
-	db, dialect, err := GetConnection(env)
+	db, _, err := GetConnection(env)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	source := migrate.FileMigrationSource{
-		Dir: env.Dir,
+	m, err := migrator.NewFromDir(db, *env)
+	if err != nil {
+		return err
+	}
+	unlock, err := LockMigrations(m)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	options := []func(*migrator.Options){
+		migrator.WithLimit(limit),
+		migrator.WithVersion(version),
+		migrator.WithDryRun(dryrun),
+		migrator.WithAllowModified(allowModified),
+	}
+	rp, err := m.Apply(context.Background(), dir, options...)
+	if errors.Is(err, migrator.ErrModified) {
+		return fmt.Errorf("%w, restore the files or run with --allow-modified", err)
+	} else if err != nil {
+		return err
 	}
 
 	if dryrun {
-		var migrations []*migrate.PlannedMigration
-
-		if version >= 0 {
-			migrations, _, err = migrate.PlanMigrationToVersion(db, dialect, source, dir, version)
-		} else {
-			migrations, _, err = migrate.PlanMigration(db, dialect, source, dir, limit)
-		}
-
-		if err != nil {
-			return fmt.Errorf("cannot plan migration: %s", err)
-		}
-
-		for _, m := range migrations {
-			PrintMigration(m, dir)
+		for _, item := range rp.Migrations {
+			PrintMigration(item, dir)
 		}
 	} else {
-		var n int
-
-		if version >= 0 {
-			n, err = migrate.ExecVersion(db, dialect, source, dir, version)
-		} else {
-			n, err = migrate.ExecMax(db, dialect, source, dir, limit)
-		}
-
-		if err != nil {
-			return fmt.Errorf("migration failed: %s", err)
-		}
-
+		n := len(rp.Migrations)
 		if n == 1 {
 			fmt.Println("Applied 1 migration")
 		} else {
@@ -60,18 +59,29 @@ func ApplyMigrations(dir migrate.MigrationDirection, dryrun bool, limit int, ver
 	return nil
 }
 
-func PrintMigration(m *migrate.PlannedMigration, dir migrate.MigrationDirection) {
-	if dir == migrate.Up {
-		fmt.Println(fmt.Sprintf("==> Would apply migration %s (up)", m.Id))
-		for _, q := range m.Up {
-			fmt.Println(q)
+// LockMigrations takes the migration lock in LockTimeout, so that processes do not migrate at the same time
+func LockMigrations(m *migrator.Migrator) (unlock func(), err error) {
+	release, err := m.Lock(context.Background(), LockTimeout)
+	if err != nil {
+		return
+	}
+	unlock = func() {
+		if e := release(); e != nil {
+			fmt.Println(fmt.Sprintf("Could not release migration lock: %v", e))
 		}
+	}
+	return
+}
+
+func PrintMigration(m migrator.Migration, dir migrate.MigrationDirection) {
+	if dir == migrate.Up {
+		fmt.Println(fmt.Sprintf("==> Would apply migration %s (up)", m.ID))
 	} else if dir == migrate.Down {
-		fmt.Println(fmt.Sprintf("==> Would apply migration %s (down)", m.Id))
-		for _, q := range m.Down {
-			fmt.Println(q)
-		}
+		fmt.Println(fmt.Sprintf("==> Would apply migration %s (down)", m.ID))
 	} else {
 		panic("Not reached")
 	}
+	for _, q := range m.Statements {
+		fmt.Println(q)
+	}
 }
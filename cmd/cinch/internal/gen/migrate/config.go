@@ -7,7 +7,9 @@ import (
 	"github.com/spf13/cobra"
 	"os"
 	"runtime/debug"
+	"time"
 
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
 	"github.com/go-gorp/gorp/v3"
 	migrate "github.com/rubenv/sql-migrate"
 	"gopkg.in/yaml.v3"
@@ -18,9 +20,9 @@ import (
 )
 
 const (
-	DefaultDialect   = "mysql"
-	DefaultDir       = "internal/db/migrations"
-	DefaultTableName = "schema_migrations"
+	DefaultDialect   = migrator.DefaultDialect
+	DefaultDir       = migrator.DefaultDir
+	DefaultTableName = migrator.DefaultTableName
 )
 
 var dialects = map[string]gorp.Dialect{
@@ -31,6 +33,7 @@ var dialects = map[string]gorp.Dialect{
 
 var ConfigFile string
 var ConfigEnvironment string
+var LockTimeout time.Duration
 
 func init() {
 
@@ -39,16 +42,11 @@ func init() {
 func ConfigFlags(f *cobra.Command) {
 	ConfigFile, _ = f.Flags().GetString("config")
 	ConfigEnvironment, _ = f.Flags().GetString("env")
+	LockTimeout, _ = f.Flags().GetDuration("lock-timeout")
 }
 
-type Environment struct {
-	Dialect       string `yaml:"dialect"`
-	DSN           string `yaml:"dsn"`
-	Dir           string `yaml:"dir"`
-	TableName     string `yaml:"table"`
-	SchemaName    string `yaml:"schema"`
-	IgnoreUnknown bool   `yaml:"ignoreunknown"`
-}
+// Environment is shared with the exported migrator, so that services read the same config
+type Environment = migrator.Environment
 
 func ReadConfig() (map[string]*Environment, error) {
 	file, err := os.ReadFile(ConfigFile)
@@ -66,31 +64,25 @@ func ReadConfig() (map[string]*Environment, error) {
 }
 
 func GetEnvironment() (*Environment, error) {
-	config, err := ReadConfig()
+	env, err := GetSourceEnvironment()
 	if err != nil {
 		return nil, err
 	}
 
-	env := config[ConfigEnvironment]
-	if env == nil {
-		return nil, errors.New("no environment: " + ConfigEnvironment)
-	}
-
-	if env.Dialect == "" {
-		env.Dialect = DefaultDialect
-	}
-
 	if env.DSN == "" {
 		return nil, errors.New("no data source specified")
 	}
 	env.DSN = os.ExpandEnv(env.DSN)
 
-	if env.Dir == "" {
-		env.Dir = DefaultDir
-	}
+	return env, nil
+}
 
-	if env.TableName == "" {
-		env.TableName = DefaultTableName
+// GetSourceEnvironment is like GetEnvironment but does not require a data source,
+// it is enough for commands which only read the migration files.
+func GetSourceEnvironment() (*Environment, error) {
+	env, err := lookupEnvironment(ConfigEnvironment)
+	if err != nil {
+		return nil, err
 	}
 
 	if env.TableName != "" {
@@ -106,6 +98,34 @@ func GetEnvironment() (*Environment, error) {
 	return env, nil
 }
 
+// lookupEnvironment returns the environment of name with defaults, sql-migrate settings are not changed,
+// so that commands can read several environments.
+func lookupEnvironment(name string) (*Environment, error) {
+	config, err := ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	env := config[name]
+	if env == nil {
+		return nil, errors.New("no environment: " + name)
+	}
+
+	if env.Dialect == "" {
+		env.Dialect = DefaultDialect
+	}
+
+	if env.Dir == "" {
+		env.Dir = DefaultDir
+	}
+
+	if env.TableName == "" {
+		env.TableName = DefaultTableName
+	}
+
+	return env, nil
+}
+
 func GetConnection(env *Environment) (*sql.DB, string, error) {
 	db, err := sql.Open(env.Dialect, env.DSN)
 	if err != nil {
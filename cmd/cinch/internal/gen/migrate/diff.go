@@ -0,0 +1,382 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-cinch/common/utils"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/spf13/cobra"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var migrateDiff = &cobra.Command{
+	Use:   "diff",
+	Short: "Show schema differences between two environments.",
+	Long:  "Show missing or extra tables, columns, indexes, column type differences and migration records between two environments, exit 1 if they differ. Example: cinch gen migrate diff --from dev --to prod",
+	Run:   MigrateDiffRun,
+}
+
+func init() {
+	migrateDiff.Flags().StringP("from", "", "", "Environment to compare from, eg: dev.")
+	migrateDiff.Flags().StringP("to", "", "", "Environment to compare to, eg: prod.")
+}
+
+// dbSchema is the schema and migration records of an environment
+type dbSchema struct {
+	env     *Environment
+	tables  map[string]*dbTable
+	records map[string]time.Time
+	// files are the migration ids in env.Dir
+	files []string
+}
+
+type dbTable struct {
+	columns []dbColumn
+	indexes []dbIndex
+}
+
+type dbColumn struct {
+	name     string
+	typ      string
+	nullable bool
+	// def and comment are kept by migrate auto when it modifies the column back
+	def     *string
+	comment string
+}
+
+func (c dbColumn) String() string {
+	if c.nullable {
+		return c.typ + " NULL"
+	}
+	return c.typ + " NOT NULL"
+}
+
+type dbIndex struct {
+	name    string
+	columns []string
+	unique  bool
+}
+
+func (i dbIndex) String() string {
+	if i.unique {
+		return fmt.Sprintf("UNIQUE (%s)", strings.Join(i.columns, ", "))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(i.columns, ", "))
+}
+
+func MigrateDiffRun(cmd *cobra.Command, args []string) {
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+	if from == "" || to == "" {
+		panic("Please provide --from and --to environments.")
+	}
+	ConfigFlags(cmd)
+
+	src, err := inspectEnvironment(from)
+	if err != nil {
+		panic(fmt.Errorf("cannot inspect %s: %s", from, err))
+	}
+	dst, err := inspectEnvironment(to)
+	if err != nil {
+		panic(fmt.Errorf("cannot inspect %s: %s", to, err))
+	}
+
+	lines := diffSchema(src, dst)
+	if len(lines) == 0 {
+		fmt.Println(fmt.Sprintf("No difference between %s and %s", from, to))
+		return
+	}
+	fmt.Println(fmt.Sprintf("--- %s", from))
+	fmt.Println(fmt.Sprintf("+++ %s", to))
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	os.Exit(1)
+}
+
+// inspectEnvironment reads tables, columns, indexes and migration records of environment name
+func inspectEnvironment(name string) (rp *dbSchema, err error) {
+	env, err := lookupEnvironment(name)
+	if err != nil {
+		return
+	}
+	if env.DSN == "" {
+		err = fmt.Errorf("no data source specified")
+		return
+	}
+	env.DSN = os.ExpandEnv(env.DSN)
+
+	gormDB, closeDB, err := openEnvironment(env)
+	if err != nil {
+		return
+	}
+	defer closeDB()
+	return inspectSchema(gormDB, env)
+}
+
+// openEnvironment connects the database of env by gorm, its migrator reads the schema
+func openEnvironment(env *Environment) (gormDB *gorm.DB, closeDB func(), err error) {
+	db, dialect, err := GetConnection(env)
+	if err != nil {
+		return
+	}
+	var dialector gorm.Dialector
+	switch dialect {
+	case "mysql":
+		dialector = mysql.New(mysql.Config{Conn: db})
+	case "postgres":
+		dialector = postgres.New(postgres.Config{Conn: db})
+	default:
+		dialector = sqlite.Dialector{Conn: db}
+	}
+	gormDB, err = gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		_ = db.Close()
+		err = fmt.Errorf("cannot connect to database: %s", err)
+		return
+	}
+	closeDB = func() {
+		_ = db.Close()
+	}
+	return
+}
+
+// inspectSchema reads tables, columns, indexes and migration records of env
+func inspectSchema(gormDB *gorm.DB, env *Environment) (rp *dbSchema, err error) {
+	rp = &dbSchema{
+		env:     env,
+		tables:  make(map[string]*dbTable),
+		records: make(map[string]time.Time),
+	}
+	tables, err := gormDB.Migrator().GetTables()
+	if err != nil {
+		err = fmt.Errorf("cannot get tables: %s", err)
+		return
+	}
+	recordTable := env.TableName
+	if env.SchemaName != "" {
+		recordTable = env.SchemaName + "." + env.TableName
+	}
+	owned := env.Tables()
+	for _, table := range tables {
+		if utils.Contains[string](owned, table) {
+			continue
+		}
+		var item *dbTable
+		item, err = inspectTable(gormDB, table)
+		if err != nil {
+			return
+		}
+		rp.tables[table] = item
+	}
+
+	if gormDB.Migrator().HasTable(recordTable) {
+		var records []struct {
+			Id        string
+			AppliedAt time.Time
+		}
+		err = gormDB.Table(recordTable).Select("id", "applied_at").Scan(&records).Error
+		if err != nil {
+			err = fmt.Errorf("cannot get migration records: %s", err)
+			return
+		}
+		for _, r := range records {
+			rp.records[r.Id] = r.AppliedAt
+		}
+	}
+
+	migrations, err := migrate.FileMigrationSource{Dir: env.Dir}.FindMigrations()
+	if err != nil {
+		err = fmt.Errorf("cannot find migrations in %s: %s", env.Dir, err)
+		return
+	}
+	for _, m := range migrations {
+		rp.files = append(rp.files, m.Id)
+	}
+	return
+}
+
+func inspectTable(gormDB *gorm.DB, table string) (rp *dbTable, err error) {
+	rp = &dbTable{}
+	cols, err := gormDB.Migrator().ColumnTypes(table)
+	if err != nil {
+		err = fmt.Errorf("cannot get columns of %s: %s", table, err)
+		return
+	}
+	for _, col := range cols {
+		item := dbColumn{
+			name: col.Name(),
+			typ:  strings.ToLower(col.DatabaseTypeName()),
+		}
+		if v, ok := col.ColumnType(); ok && v != "" {
+			item.typ = strings.ToLower(v)
+		}
+		item.nullable, _ = col.Nullable()
+		item.comment, _ = col.Comment()
+		if v, ok := col.DefaultValue(); ok {
+			item.def = &v
+		}
+		rp.columns = append(rp.columns, item)
+	}
+	if gormDB.Dialector.Name() == "sqlite" {
+		// sqlite migrator parses not null from create table statement, it is lost by alter table
+		var infos []struct {
+			Name    string `gorm:"column:name"`
+			NotNull bool   `gorm:"column:notnull"`
+			Pk      int    `gorm:"column:pk"`
+		}
+		err = gormDB.Raw(fmt.Sprintf("PRAGMA table_info(%q)", table)).Scan(&infos).Error
+		if err != nil {
+			err = fmt.Errorf("cannot get columns of %s: %s", table, err)
+			return
+		}
+		for _, info := range infos {
+			for i := range rp.columns {
+				if rp.columns[i].name == info.Name {
+					rp.columns[i].nullable = !info.NotNull && info.Pk == 0
+				}
+			}
+		}
+	}
+	rp.indexes, err = inspectIndexes(gormDB, table)
+	return
+}
+
+// inspectIndexes returns indexes of table, sqlite migrator does not support GetIndexes, PRAGMA is used
+func inspectIndexes(gormDB *gorm.DB, table string) (list []dbIndex, err error) {
+	if gormDB.Dialector.Name() == "sqlite" {
+		var indexes []struct {
+			Name   string `gorm:"column:name"`
+			Unique bool   `gorm:"column:unique"`
+		}
+		err = gormDB.Raw(fmt.Sprintf("PRAGMA index_list(%q)", table)).Scan(&indexes).Error
+		if err != nil {
+			err = fmt.Errorf("cannot get indexes of %s: %s", table, err)
+			return
+		}
+		for _, idx := range indexes {
+			var cols []struct {
+				Name string `gorm:"column:name"`
+			}
+			err = gormDB.Raw(fmt.Sprintf("PRAGMA index_info(%q)", idx.Name)).Scan(&cols).Error
+			if err != nil {
+				err = fmt.Errorf("cannot get index %s of %s: %s", idx.Name, table, err)
+				return
+			}
+			item := dbIndex{name: idx.Name, unique: idx.Unique}
+			for _, col := range cols {
+				item.columns = append(item.columns, col.Name)
+			}
+			list = append(list, item)
+		}
+		return
+	}
+	indexes, err := gormDB.Migrator().GetIndexes(table)
+	if err != nil {
+		err = fmt.Errorf("cannot get indexes of %s: %s", table, err)
+		return
+	}
+	for _, idx := range indexes {
+		item := dbIndex{name: idx.Name(), columns: idx.Columns()}
+		item.unique, _ = idx.Unique()
+		list = append(list, item)
+	}
+	return
+}
+
+// diffSchema returns the differences from src to dst, - is only in src, + is only in dst and ~ is changed
+func diffSchema(src, dst *dbSchema) (lines []string) {
+	for _, table := range unionKeys(src.tables, dst.tables) {
+		from, to := src.tables[table], dst.tables[table]
+		switch {
+		case to == nil:
+			lines = append(lines, fmt.Sprintf("- table %s", table))
+			continue
+		case from == nil:
+			lines = append(lines, fmt.Sprintf("+ table %s", table))
+			continue
+		}
+		fromCols, toCols := make(map[string]dbColumn), make(map[string]dbColumn)
+		for _, col := range from.columns {
+			fromCols[col.name] = col
+		}
+		for _, col := range to.columns {
+			toCols[col.name] = col
+		}
+		for _, name := range unionKeys(fromCols, toCols) {
+			a, inFrom := fromCols[name]
+			b, inTo := toCols[name]
+			switch {
+			case !inTo:
+				lines = append(lines, fmt.Sprintf("- column %s.%s %s", table, name, a))
+			case !inFrom:
+				lines = append(lines, fmt.Sprintf("+ column %s.%s %s", table, name, b))
+			case a.String() != b.String():
+				lines = append(lines, fmt.Sprintf("~ column %s.%s %s => %s", table, name, a, b))
+			}
+		}
+		fromIdx, toIdx := make(map[string]dbIndex), make(map[string]dbIndex)
+		for _, idx := range from.indexes {
+			fromIdx[idx.name] = idx
+		}
+		for _, idx := range to.indexes {
+			toIdx[idx.name] = idx
+		}
+		for _, name := range unionKeys(fromIdx, toIdx) {
+			a, inFrom := fromIdx[name]
+			b, inTo := toIdx[name]
+			switch {
+			case !inTo:
+				lines = append(lines, fmt.Sprintf("- index %s.%s %s", table, name, a))
+			case !inFrom:
+				lines = append(lines, fmt.Sprintf("+ index %s.%s %s", table, name, b))
+			case a.String() != b.String():
+				lines = append(lines, fmt.Sprintf("~ index %s.%s %s => %s", table, name, a, b))
+			}
+		}
+	}
+
+	// a record without migration file is applied by hand or from another branch
+	note := func(s *dbSchema, id string) string {
+		for _, item := range s.files {
+			if item == id {
+				return ""
+			}
+		}
+		return fmt.Sprintf(", no migration file in %s", s.env.Dir)
+	}
+	for _, id := range unionKeys(src.records, dst.records) {
+		a, inFrom := src.records[id]
+		b, inTo := dst.records[id]
+		switch {
+		case !inTo:
+			lines = append(lines, fmt.Sprintf("- migration %s (applied at %s%s)", id, a.Format(time.RFC3339), note(src, id)))
+		case !inFrom:
+			lines = append(lines, fmt.Sprintf("+ migration %s (applied at %s%s)", id, b.Format(time.RFC3339), note(dst, id)))
+		}
+	}
+	return
+}
+
+// unionKeys returns the sorted keys of a and b
+func unionKeys[T any](a, b map[string]T) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
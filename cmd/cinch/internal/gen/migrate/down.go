@@ -23,7 +23,7 @@ func MigrateDownRun(cmd *cobra.Command, args []string) {
 	version, _ := cmd.Flags().GetInt64("version")
 	dryrun, _ := cmd.Flags().GetBool("dryrun")
 	ConfigFlags(cmd)
-	err := ApplyMigrations(migrate.Down, dryrun, limit, version)
+	err := ApplyMigrations(migrate.Down, dryrun, limit, version, false)
 	if err != nil {
 		panic(err)
 	}
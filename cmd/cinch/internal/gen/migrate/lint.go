@@ -0,0 +1,371 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-cinch/common/utils"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/spf13/cobra"
+)
+
+var migrateLint = &cobra.Command{
+	Use:   "lint",
+	Short: "Check migrations for destructive and unsafe statements.",
+	Long: `Check migrations for destructive and unsafe statements, exit 1 if any error is found.
+A rule is allowed for the next statement by a comment: -- cinch:allow destructive,mysql-lock
+Rules: missing-down, empty-down, destructive, mysql-lock, not-null-without-default, dialect`,
+	Run: MigrateLintRun,
+}
+
+func init() {
+	migrateLint.Flags().StringP("output", "o", "text", "Output format: text|json.")
+}
+
+const (
+	lintError   = "error"
+	lintWarning = "warning"
+)
+
+// lintAllow is the comment which allows rules for the next statement
+const lintAllow = "-- cinch:allow "
+
+// LintIssue is a problem of a migration file
+type LintIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// lintStatement is a statement of a migration section
+type lintStatement struct {
+	line  int
+	sql   string
+	allow []string
+}
+
+// lintRule checks a statement of the up section
+type lintRule struct {
+	name     string
+	severity string
+	check    func(dialect, sql string) string
+}
+
+var (
+	lintQuoted          = regexp.MustCompile(`'(?:[^']|'')*'`)
+	lintDropTable       = regexp.MustCompile(`(?i)\bDROP\s+TABLE\b`)
+	lintDropColumn      = regexp.MustCompile(`(?i)^\s*DROP\s+(COLUMN\s+)?(IF\s+EXISTS\s+)?([` + "`" + `"\w]+)`)
+	lintTruncate        = regexp.MustCompile(`(?i)\bTRUNCATE\b`)
+	lintAlterTable      = regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\b`)
+	lintCopyAlter       = regexp.MustCompile(`(?i)\b(MODIFY\s+(COLUMN\s+)?|CHANGE\s+(COLUMN\s+)?|CONVERT\s+TO\s+CHARACTER\s+SET|ADD\s+PRIMARY\s+KEY|DROP\s+PRIMARY\s+KEY|ENGINE\s*=|ROW_FORMAT\s*=|ADD\s+(FULLTEXT|SPATIAL)\b)`)
+	lintOnlineAlter     = regexp.MustCompile(`(?i)\bALGORITHM\s*=\s*(INSTANT|INPLACE)\b`)
+	lintLockNone        = regexp.MustCompile(`(?i)\bLOCK\s*=\s*NONE\b`)
+	lintAddColumn       = regexp.MustCompile(`(?i)\bADD\s+(COLUMN\s+)?(IF\s+NOT\s+EXISTS\s+)?[` + "`" + `"\w]+\s+[^,]*`)
+	lintNotNull         = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	lintDefault         = regexp.MustCompile(`(?i)\bDEFAULT\b|\bAUTO_INCREMENT\b|\bGENERATED\b|\bAS\s*\(`)
+	lintAlterPrefix     = regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\s+\S+\s+`)
+	lintAddConstraint   = regexp.MustCompile(`(?i)^\s*ADD\s+(CONSTRAINT|PRIMARY|UNIQUE|INDEX|KEY|FOREIGN|FULLTEXT|SPATIAL|CHECK)\b`)
+	lintDialectKeywords = map[string][]*regexp.Regexp{
+		"mysql": {
+			regexp.MustCompile("`"),
+			regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`),
+			regexp.MustCompile(`(?i)\bENGINE\s*=`),
+			regexp.MustCompile(`(?i)\bUNSIGNED\b`),
+			regexp.MustCompile(`(?i)\bMODIFY\s+(COLUMN\s+)?\w`),
+			regexp.MustCompile(`(?i)\bON\s+UPDATE\s+CURRENT_TIMESTAMP\b`),
+		},
+		"postgres": {
+			regexp.MustCompile(`(?i)\b(BIG|SMALL)?SERIAL\b`),
+			regexp.MustCompile(`::\w`),
+			regexp.MustCompile(`(?i)\bJSONB\b`),
+			regexp.MustCompile(`(?i)\bCREATE\s+EXTENSION\b`),
+			regexp.MustCompile(`(?i)\bALTER\s+COLUMN\s+\S+\s+(SET\s+DATA\s+)?TYPE\b`),
+			regexp.MustCompile(`(?i)\bBYTEA\b`),
+		},
+		"sqlite3": {
+			regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`),
+			regexp.MustCompile(`(?i)\bPRAGMA\b`),
+			regexp.MustCompile(`(?i)\bWITHOUT\s+ROWID\b`),
+		},
+	}
+)
+
+var lintRules = []lintRule{
+	{
+		name:     "destructive",
+		severity: lintError,
+		check: func(_, sql string) string {
+			switch {
+			case lintDropTable.MatchString(sql):
+				return "DROP TABLE loses data"
+			case lintTruncate.MatchString(sql):
+				return "TRUNCATE loses data"
+			}
+			if !lintAlterTable.MatchString(sql) {
+				return ""
+			}
+			for _, clause := range splitClauses(sql) {
+				if isDropColumn(clause) {
+					return "DROP COLUMN loses data"
+				}
+			}
+			return ""
+		},
+	},
+	{
+		name:     "mysql-lock",
+		severity: lintWarning,
+		check: func(dialect, sql string) string {
+			if dialect != "mysql" || !lintAlterTable.MatchString(sql) {
+				return ""
+			}
+			if m := lintCopyAlter.FindString(sql); m != "" && !(lintOnlineAlter.MatchString(sql) && lintLockNone.MatchString(sql)) {
+				return fmt.Sprintf("ALTER TABLE with %s may copy the table and block writes, use ALGORITHM=INPLACE, LOCK=NONE or an online schema change tool for large tables", strings.ToUpper(strings.TrimSpace(m)))
+			}
+			return ""
+		},
+	},
+	{
+		name:     "not-null-without-default",
+		severity: lintError,
+		check: func(_, sql string) string {
+			if !lintAlterTable.MatchString(sql) {
+				return ""
+			}
+			for _, clause := range splitClauses(sql) {
+				if lintAddConstraint.MatchString(clause) || !lintAddColumn.MatchString(clause) {
+					continue
+				}
+				if lintNotNull.MatchString(clause) && !lintDefault.MatchString(clause) {
+					return fmt.Sprintf("%s fails or locks on a table which has rows, add a DEFAULT", strings.TrimRight(strings.TrimSpace(clause), ";"))
+				}
+			}
+			return ""
+		},
+	},
+	{
+		name:     "dialect",
+		severity: lintError,
+		check: func(dialect, sql string) string {
+			for _, other := range []string{"mysql", "postgres", "sqlite3"} {
+				if other == dialect {
+					continue
+				}
+				for _, keyword := range lintDialectKeywords[other] {
+					if m := keyword.FindString(sql); m != "" && !dialectKeywordShared(dialect, keyword) {
+						return fmt.Sprintf("%s is %s syntax, the dialect is %s", strings.TrimSpace(m), other, dialect)
+					}
+				}
+			}
+			return ""
+		},
+	},
+}
+
+func MigrateLintRun(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		panic(fmt.Sprintf("Unsupported output %s, support text || json.", output))
+	}
+	ConfigFlags(cmd)
+	env, err := GetSourceEnvironment()
+	if err != nil {
+		panic(err)
+	}
+
+	issues, err := LintMigrations(env)
+	if err != nil {
+		panic(err)
+	}
+
+	errs := 0
+	for _, item := range issues {
+		if item.Severity == lintError {
+			errs++
+		}
+	}
+	if output == "json" {
+		if issues == nil {
+			issues = make([]LintIssue, 0)
+		}
+		b, _ := json.MarshalIndent(issues, "", "  ")
+		fmt.Println(string(b))
+	} else {
+		for _, item := range issues {
+			fmt.Println(fmt.Sprintf("%s:%d: %s %s: %s", item.File, item.Line, item.Severity, item.Rule, item.Message))
+		}
+		fmt.Println(fmt.Sprintf("%d errors, %d warnings", errs, len(issues)-errs))
+	}
+	if errs > 0 {
+		os.Exit(1)
+	}
+}
+
+// LintMigrations checks every migration of env.Dir
+func LintMigrations(env *Environment) (issues []LintIssue, err error) {
+	migrations, err := migrate.FileMigrationSource{Dir: env.Dir}.FindMigrations()
+	if err != nil {
+		err = fmt.Errorf("cannot find migrations: %s", err)
+		return
+	}
+	for _, m := range migrations {
+		file := filepath.Join(env.Dir, m.Id)
+		var content []byte
+		content, err = os.ReadFile(file)
+		if err != nil {
+			return
+		}
+		issues = append(issues, lintFile(env.Dialect, file, content)...)
+	}
+	return
+}
+
+// lintFile checks the statements of up section by rules, and down section is not empty
+func lintFile(dialect, file string, content []byte) (issues []LintIssue) {
+	up, down, hasDown, downLine := lintSections(content)
+	for _, stmt := range up {
+		for _, rule := range lintRules {
+			if containsRule(stmt.allow, rule.name) {
+				continue
+			}
+			if msg := rule.check(dialect, stmt.sql); msg != "" {
+				issues = append(issues, LintIssue{File: file, Line: stmt.line, Rule: rule.name, Severity: rule.severity, Message: msg})
+			}
+		}
+	}
+	switch {
+	case !hasDown:
+		issues = append(issues, LintIssue{File: file, Line: 1, Rule: "missing-down", Severity: lintError, Message: "-- +migrate Down is missing, the migration cannot be rolled back"})
+	case len(down) == 0:
+		issues = append(issues, LintIssue{File: file, Line: downLine, Rule: "empty-down", Severity: lintError, Message: "-- +migrate Down is empty, the migration cannot be rolled back"})
+	}
+	return
+}
+
+// lintSections splits the up and down sections into statements as sql-migrate does, comments and string literals are removed
+func lintSections(content []byte) (up, down []lintStatement, hasDown bool, downLine int) {
+	var (
+		section *[]lintStatement
+		buf     strings.Builder
+		start   int
+		allow   []string
+		inBlock bool
+	)
+	flush := func() {
+		if section != nil && strings.TrimSpace(buf.String()) != "" {
+			*section = append(*section, lintStatement{line: start, sql: buf.String(), allow: allow})
+		}
+		buf.Reset()
+		start = 0
+		allow = nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "-- +migrate ") {
+			fields := strings.Fields(strings.TrimPrefix(trimmed, "-- +migrate "))
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "Up":
+				flush()
+				section = &up
+			case "Down":
+				flush()
+				section = &down
+				hasDown = true
+				downLine = n
+			case "StatementBegin":
+				inBlock = true
+			case "StatementEnd":
+				inBlock = false
+				flush()
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, lintAllow) {
+			for _, item := range strings.Split(strings.TrimPrefix(trimmed, lintAllow), ",") {
+				allow = append(allow, strings.TrimSpace(item))
+			}
+			continue
+		}
+		code := lintQuoted.ReplaceAllString(line, "''")
+		if i := strings.Index(code, "--"); i >= 0 {
+			code = code[:i]
+		}
+		if strings.TrimSpace(code) == "" {
+			continue
+		}
+		if start == 0 {
+			start = n
+		}
+		buf.WriteString(code + "\n")
+		if !inBlock && strings.HasSuffix(strings.TrimSpace(code), ";") {
+			flush()
+		}
+	}
+	flush()
+	return
+}
+
+// splitClauses splits the clauses of ALTER TABLE by commas which are not in parentheses
+func splitClauses(sql string) (list []string) {
+	depth, last := 0, 0
+	for i, c := range sql {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				list = append(list, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+	list = append(list, sql[last:])
+	// the first clause starts with ALTER TABLE name
+	if loc := lintAlterPrefix.FindStringIndex(list[0]); loc != nil {
+		list[0] = list[0][loc[1]:]
+	}
+	return
+}
+
+// lintDropKeywords are the objects which DROP of ALTER TABLE drops other than columns
+var lintDropKeywords = []string{"INDEX", "KEY", "PRIMARY", "FOREIGN", "CONSTRAINT", "CHECK", "PARTITION", "NOT", "DEFAULT", "IDENTITY", "EXPRESSION"}
+
+// isDropColumn reports whether an ALTER TABLE clause drops a column, DROP without COLUMN drops a column unless it is followed by a keyword
+func isDropColumn(clause string) bool {
+	m := lintDropColumn.FindStringSubmatch(clause)
+	if m == nil {
+		return false
+	}
+	return m[1] != "" || !utils.Contains[string](lintDropKeywords, strings.ToUpper(m[3]))
+}
+
+// dialectKeywordShared reports whether keyword of another dialect is also valid in dialect
+func dialectKeywordShared(dialect string, keyword *regexp.Regexp) bool {
+	// sqlite accepts the identifier quote of mysql
+	return keyword.String() == "`" && dialect == "sqlite3"
+}
+
+func containsRule(list []string, rule string) bool {
+	for _, item := range list {
+		if item == rule || item == "all" {
+			return true
+		}
+	}
+	return false
+}
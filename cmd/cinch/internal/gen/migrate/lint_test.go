@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestLintFile(t *testing.T) {
+	cases := []struct {
+		name    string
+		dialect string
+		up      string
+		down    string
+		noDown  bool
+		rule    string
+	}{
+		// destructive
+		{name: "drop table", dialect: "mysql", up: "DROP TABLE users;", rule: "destructive"},
+		{name: "truncate", dialect: "postgres", up: "TRUNCATE users;", rule: "destructive"},
+		{name: "drop column", dialect: "postgres", up: `ALTER TABLE users DROP COLUMN "email";`, rule: "destructive"},
+		{name: "drop column without keyword", dialect: "mysql", up: "ALTER TABLE users DROP email;", rule: "destructive"},
+		{name: "drop column if exists", dialect: "postgres", up: "ALTER TABLE users DROP COLUMN IF EXISTS email;", rule: "destructive"},
+		{name: "drop column before drop index", dialect: "mysql", up: "ALTER TABLE users DROP COLUMN a, DROP INDEX idx_b;", rule: "destructive"},
+		{name: "drop column after drop index", dialect: "mysql", up: "ALTER TABLE users DROP INDEX idx_b, DROP COLUMN a;", rule: "destructive"},
+		{name: "drop column named like keyword", dialect: "postgres", up: `ALTER TABLE users DROP COLUMN "default";`, rule: "destructive"},
+		{name: "drop not null", dialect: "postgres", up: `ALTER TABLE users ALTER COLUMN "email" DROP NOT NULL;`},
+		{name: "drop default", dialect: "postgres", up: "ALTER TABLE users ALTER COLUMN email DROP DEFAULT;"},
+		{name: "drop identity", dialect: "postgres", up: "ALTER TABLE users ALTER COLUMN id DROP IDENTITY IF EXISTS;"},
+		{name: "drop expression", dialect: "postgres", up: "ALTER TABLE users ALTER COLUMN total DROP EXPRESSION;"},
+		{name: "drop index", dialect: "mysql", up: "ALTER TABLE users DROP INDEX idx_email;"},
+		{name: "drop constraint", dialect: "postgres", up: "ALTER TABLE users DROP CONSTRAINT uk_email;"},
+		{name: "drop foreign key", dialect: "mysql", up: "ALTER TABLE users DROP FOREIGN KEY fk_org;"},
+		{name: "drop index statement", dialect: "postgres", up: "DROP INDEX idx_email;"},
+		{name: "drop in string", dialect: "postgres", up: "INSERT INTO logs (msg) VALUES ('DROP TABLE users');"},
+		{name: "allowed", dialect: "mysql", up: "-- cinch:allow destructive\nDROP TABLE users;"},
+
+		// mysql-lock
+		{name: "modify column", dialect: "mysql", up: "ALTER TABLE users MODIFY COLUMN email VARCHAR(255) NOT NULL DEFAULT '';", rule: "mysql-lock"},
+		{name: "add primary key", dialect: "mysql", up: "ALTER TABLE users ADD PRIMARY KEY (id);", rule: "mysql-lock"},
+		{name: "modify column online", dialect: "mysql", up: "ALTER TABLE users MODIFY COLUMN email VARCHAR(255) NOT NULL DEFAULT '', ALGORITHM=INPLACE, LOCK=NONE;"},
+		{name: "add column", dialect: "mysql", up: "ALTER TABLE users ADD COLUMN age INT;"},
+		{name: "modify column of postgres", dialect: "postgres", up: "ALTER TABLE users ALTER COLUMN email SET DEFAULT '';"},
+
+		// not-null-without-default
+		{name: "add not null column", dialect: "postgres", up: "ALTER TABLE users ADD COLUMN age INT NOT NULL;", rule: "not-null-without-default"},
+		{name: "add not null column in second clause", dialect: "mysql", up: "ALTER TABLE users ADD COLUMN a INT, ADD COLUMN b INT NOT NULL;", rule: "not-null-without-default"},
+		{name: "add not null column with default", dialect: "postgres", up: "ALTER TABLE users ADD COLUMN age INT NOT NULL DEFAULT 0;"},
+		{name: "add nullable column", dialect: "postgres", up: "ALTER TABLE users ADD COLUMN age INT;"},
+		{name: "add not null constraint", dialect: "postgres", up: "ALTER TABLE users ADD CONSTRAINT ck_age CHECK (age IS NOT NULL);"},
+		{name: "create table not null", dialect: "postgres", up: "CREATE TABLE users (id BIGINT NOT NULL);"},
+
+		// dialect
+		{name: "mysql quote in postgres", dialect: "postgres", up: "CREATE TABLE `users` (id BIGINT);", rule: "dialect"},
+		{name: "serial in mysql", dialect: "mysql", up: "CREATE TABLE users (id BIGSERIAL);", rule: "dialect"},
+		{name: "autoincrement in postgres", dialect: "postgres", up: "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT);", rule: "dialect"},
+		{name: "mysql quote in sqlite", dialect: "sqlite3", up: "CREATE TABLE `users` (id INTEGER);"},
+		{name: "serial in postgres", dialect: "postgres", up: "CREATE TABLE users (id BIGSERIAL);"},
+
+		// missing-down and empty-down
+		{name: "missing down", dialect: "postgres", up: "CREATE TABLE users (id BIGINT);", noDown: true, rule: "missing-down"},
+		{name: "empty down", dialect: "postgres", up: "CREATE TABLE users (id BIGINT);", down: "-- nothing", rule: "empty-down"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			content := "-- +migrate Up\n" + c.up + "\n"
+			if !c.noDown {
+				down := c.down
+				if down == "" {
+					down = "SELECT 1;"
+				}
+				content += "-- +migrate Down\n" + down + "\n"
+			}
+			issues := lintFile(c.dialect, "test.sql", []byte(content))
+			if c.rule == "" {
+				if len(issues) > 0 {
+					t.Fatalf("want no issue, got %+v", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || issues[0].Rule != c.rule {
+				t.Fatalf("want %s, got %+v", c.rule, issues)
+			}
+		})
+	}
+}
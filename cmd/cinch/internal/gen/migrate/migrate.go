@@ -1,6 +1,9 @@
 package migrate
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
+	"github.com/spf13/cobra"
+)
 
 const (
 	DefaultConfig = "./configs/gen.yml"
@@ -20,7 +23,12 @@ func init() {
 	CmdMigrate.AddCommand(migrateStatus)
 	CmdMigrate.AddCommand(migrateNew)
 	CmdMigrate.AddCommand(migrateSkip)
+	CmdMigrate.AddCommand(migrateDiff)
+	CmdMigrate.AddCommand(migrateAuto)
+	CmdMigrate.AddCommand(migrateLint)
+	CmdMigrate.AddCommand(migrateUnlock)
 
 	CmdMigrate.PersistentFlags().StringP("config", "c", DefaultConfig, "Database configuration file.")
 	CmdMigrate.PersistentFlags().StringP("env", "e", DefaultEnv, "Environment.")
+	CmdMigrate.PersistentFlags().Duration("lock-timeout", migrator.DefaultLockTimeout, "Time to wait for the migration lock held by another process.")
 }
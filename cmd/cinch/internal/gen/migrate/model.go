@@ -0,0 +1,318 @@
+package migrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DefaultOutPath      = "internal/data/query"
+	DefaultModelPkgName = "internal/data/model"
+)
+
+// modelTable is a model struct which has TableName method
+type modelTable struct {
+	name    string
+	model   string
+	columns []modelColumn
+	indexes []dbIndex
+}
+
+// modelColumn is a field of model, it is parsed from gorm tag as gorm does
+type modelColumn struct {
+	name  string
+	field string
+	// goType is the field type, local named types are resolved to the underlying type
+	goType string
+	// typ is the type tag, empty if the model is generated without --fieldWithTypeTag
+	typ string
+	// serializer is the serializer tag, the column type is mapped by it instead of field type
+	serializer    string
+	size          int
+	notNull       bool
+	primaryKey    bool
+	autoIncrement bool
+	def           *string
+	comment       string
+}
+
+// relationTagKeys are gorm tag keys of association fields, they are not columns
+var relationTagKeys = []string{"FOREIGNKEY", "REFERENCES", "MANY2MANY", "JOINFOREIGNKEY", "JOINREFERENCES", "POLYMORPHIC"}
+
+// modelGen is the part of gen in config file which decides the model directory, keys of a source overwrite gen
+type modelGen struct {
+	OutPath      string   `yaml:"out-path"`
+	ModelPkgName string   `yaml:"model-pkg-name"`
+	Schemas      []string `yaml:"schemas"`
+	SchemaLayout string   `yaml:"schema-layout"`
+}
+
+// modelDir returns the model directory of gen gorm in config file, same as cinch gen gorm writes,
+// source is required if gen has sources, models of schema packages are not included
+func modelDir(source string) (string, error) {
+	file, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		return "", err
+	}
+	var config struct {
+		Gen struct {
+			modelGen `yaml:",inline"`
+			Sources  []struct {
+				Name     string `yaml:"name"`
+				modelGen `yaml:",inline"`
+			} `yaml:"sources"`
+		} `yaml:"gen"`
+	}
+	err = yaml.Unmarshal(file, &config)
+	if err != nil {
+		return "", err
+	}
+	gen := config.Gen.modelGen
+	if len(config.Gen.Sources) > 0 {
+		names := make([]string, 0, len(config.Gen.Sources))
+		found := false
+		for _, item := range config.Gen.Sources {
+			names = append(names, item.Name)
+			if item.Name != source {
+				continue
+			}
+			found = true
+			if item.OutPath != "" {
+				gen.OutPath = item.OutPath
+			}
+			if item.ModelPkgName != "" {
+				gen.ModelPkgName = item.ModelPkgName
+			}
+			if item.Schemas != nil {
+				gen.Schemas = item.Schemas
+			}
+			if item.SchemaLayout != "" {
+				gen.SchemaLayout = item.SchemaLayout
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("gen in %s has sources, set --source to one of %s or set --model", ConfigFile, strings.Join(names, "/"))
+		}
+	} else if source != "" {
+		return "", fmt.Errorf("--source %s is set but gen in %s has no sources", source, ConfigFile)
+	}
+	outPath, modelPkgName := gen.OutPath, gen.ModelPkgName
+	if outPath == "" {
+		outPath = DefaultOutPath
+	}
+	if modelPkgName == "" {
+		modelPkgName = DefaultModelPkgName
+	}
+	dir := filepath.Join(filepath.Dir(outPath), modelPkgName)
+	if strings.Contains(modelPkgName, string(os.PathSeparator)) {
+		dir = modelPkgName
+	}
+	if gen.SchemaLayout == "package" && len(gen.Schemas) > 0 {
+		fmt.Println(fmt.Sprintf("Models of schemas %s are skipped, schema-qualified tables are not supported.", strings.Join(gen.Schemas, ", ")))
+	}
+	return dir, nil
+}
+
+// parseModels parses the go files of dir, every struct which has TableName method is a table
+func parseModels(dir string) (list []modelTable, err error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		err = fmt.Errorf("cannot parse models in %s: %s", dir, err)
+		return
+	}
+	consts := make(map[string]string)
+	decls := make(map[string]ast.Expr)
+	tableNames := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				switch d := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						switch sp := spec.(type) {
+						case *ast.TypeSpec:
+							decls[sp.Name.Name] = sp.Type
+						case *ast.ValueSpec:
+							for i, name := range sp.Names {
+								if i < len(sp.Values) {
+									if lit, ok := sp.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+										consts[name.Name], _ = strconv.Unquote(lit.Value)
+									}
+								}
+							}
+						}
+					}
+				case *ast.FuncDecl:
+					if recv, table := tableNameMethod(d); recv != "" {
+						tableNames[recv] = table
+					}
+				}
+			}
+		}
+	}
+	for model, table := range tableNames {
+		// TableName returns a constant as gen does
+		if v, ok := consts[table]; ok {
+			tableNames[model] = v
+		}
+	}
+
+	for model, table := range tableNames {
+		st, ok := decls[model].(*ast.StructType)
+		if !ok || table == "" {
+			continue
+		}
+		if strings.Contains(table, ".") {
+			err = fmt.Errorf("table %s of model %s is schema-qualified, which is not supported, write its migration by hand", table, model)
+			return
+		}
+		item := modelTable{name: table, model: model}
+		indexes := make(map[string]*dbIndex)
+		for _, f := range st.Fields.List {
+			// embedded structs such as gorm.Model are not generated by gen
+			if len(f.Names) == 0 {
+				continue
+			}
+			var tag string
+			if f.Tag != nil {
+				tag, _ = strconv.Unquote(f.Tag.Value)
+			}
+			gormTag := reflect.StructTag(tag).Get("gorm")
+			if gormTag == "-" {
+				continue
+			}
+			settings := schema.ParseTagSetting(gormTag, ";")
+			goType := resolveType(decls, f.Type)
+			if isRelation(settings, goType, tableNames) {
+				continue
+			}
+			for _, name := range f.Names {
+				col := modelColumn{
+					name:          settings["COLUMN"],
+					field:         name.Name,
+					goType:        goType,
+					typ:           settings["TYPE"],
+					serializer:    strings.ToLower(settings["SERIALIZER"]),
+					primaryKey:    hasTag(settings, "PRIMARYKEY") || hasTag(settings, "PRIMARY_KEY"),
+					autoIncrement: hasTag(settings, "AUTOINCREMENT") && settings["AUTOINCREMENT"] != "false",
+					comment:       settings["COMMENT"],
+				}
+				if col.name == "" {
+					col.name = schema.NamingStrategy{}.ColumnName("", name.Name)
+				}
+				col.notNull = hasTag(settings, "NOT NULL") || hasTag(settings, "NOTNULL") || col.primaryKey
+				if v, ok := settings["DEFAULT"]; ok {
+					col.def = &v
+				}
+				if v, ok := settings["SIZE"]; ok {
+					col.size, _ = strconv.Atoi(v)
+				}
+				item.columns = append(item.columns, col)
+				addIndexes(indexes, table, col.name, settings)
+			}
+		}
+		for _, idx := range indexes {
+			item.indexes = append(item.indexes, *idx)
+		}
+		sort.Slice(item.indexes, func(i, j int) bool {
+			return item.indexes[i].name < item.indexes[j].name
+		})
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return
+}
+
+// tableNameMethod returns the receiver and the returned constant or literal of TableName method
+func tableNameMethod(fn *ast.FuncDecl) (recv, table string) {
+	if fn.Name.Name != "TableName" || fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Body == nil || len(fn.Body.List) != 1 {
+		return
+	}
+	expr := fn.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return
+	}
+	ret, ok := fn.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return
+	}
+	switch v := ret.Results[0].(type) {
+	case *ast.Ident:
+		return ident.Name, v.Name
+	case *ast.BasicLit:
+		table, _ = strconv.Unquote(v.Value)
+		return ident.Name, table
+	}
+	return
+}
+
+// resolveType returns the type expression of field, pointer is removed and local named types are resolved
+func resolveType(decls map[string]ast.Expr, expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		if underlying, ok := decls[ident.Name]; ok {
+			if _, isStruct := underlying.(*ast.StructType); !isStruct {
+				return resolveType(decls, underlying)
+			}
+		}
+	}
+	return types.ExprString(expr)
+}
+
+// isRelation reports whether field is an association of another model
+func isRelation(settings map[string]string, goType string, tableNames map[string]string) bool {
+	for _, key := range relationTagKeys {
+		if hasTag(settings, key) {
+			return true
+		}
+	}
+	_, ok := tableNames[strings.TrimPrefix(goType, "[]")]
+	return ok
+}
+
+func hasTag(settings map[string]string, key string) bool {
+	_, ok := settings[key]
+	return ok
+}
+
+// addIndexes adds index and uniqueIndex of gorm tag to indexes, Example: index:idx_name,priority:1
+func addIndexes(indexes map[string]*dbIndex, table, column string, settings map[string]string) {
+	for _, key := range []string{"INDEX", "UNIQUEINDEX"} {
+		v, ok := settings[key]
+		if !ok {
+			continue
+		}
+		// the value is the key if index has no name
+		name := strings.TrimSpace(strings.Split(v, ",")[0])
+		if name == "" || name == key || strings.Contains(name, ":") {
+			name = fmt.Sprintf("idx_%s_%s", table, column)
+		}
+		if indexes[name] == nil {
+			indexes[name] = &dbIndex{name: name, unique: key == "UNIQUEINDEX"}
+		}
+		indexes[name].columns = append(indexes[name].columns, column)
+	}
+}
@@ -1,8 +1,11 @@
 package migrate
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	migrate "github.com/rubenv/sql-migrate"
+
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
 	"github.com/spf13/cobra"
 )
 
@@ -15,46 +18,44 @@ var migrateRedo = &cobra.Command{
 
 func init() {
 	migrateRedo.Flags().BoolP("dryrun", "d", false, "Don't apply migrations, just print them.")
+	migrateRedo.Flags().Bool("allow-modified", false, "Reapply the migration even if an applied migration is modified, the new checksums are recorded.")
 }
 
 func MigrateRedoRun(cmd *cobra.Command, args []string) {
 	dryrun, _ := cmd.Flags().GetBool("dryrun")
+	allowModified, _ := cmd.Flags().GetBool("allow-modified")
 	ConfigFlags(cmd)
 	env, err := GetEnvironment()
 	if err != nil {
 		panic(err)
 	}
-	db, dialect, err := GetConnection(env)
+	db, _, err := GetConnection(env)
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
 
-	source := migrate.FileMigrationSource{
-		Dir: env.Dir,
+	m, err := migrator.NewFromDir(db, *env)
+	if err != nil {
+		panic(err)
 	}
-
-	migrations, _, err := migrate.PlanMigration(db, dialect, source, migrate.Down, 1)
+	unlock, err := LockMigrations(m)
 	if err != nil {
-		panic(fmt.Sprintf("Migration (redo) failed: %v", err))
-	} else if len(migrations) == 0 {
+		panic(err)
+	}
+	defer unlock()
+	rp, err := m.Redo(context.Background(), migrator.WithDryRun(dryrun), migrator.WithAllowModified(allowModified))
+	if errors.Is(err, migrator.ErrNothingToRedo) {
 		panic("Nothing to do!")
+	} else if err != nil {
+		panic(fmt.Sprintf("Migration (redo) failed: %v", err))
 	}
 
 	if dryrun {
-		PrintMigration(migrations[0], migrate.Down)
-		PrintMigration(migrations[0], migrate.Up)
-	} else {
-		_, err := migrate.ExecMax(db, dialect, source, migrate.Down, 1)
-		if err != nil {
-			panic(fmt.Sprintf("Migration (down) failed: %s", err))
-		}
-
-		_, err = migrate.ExecMax(db, dialect, source, migrate.Up, 1)
-		if err != nil {
-			panic(fmt.Sprintf("Migration (up) failed: %s", err))
+		for _, item := range rp {
+			PrintMigration(item.Migrations[0], item.Direction)
 		}
-
-		fmt.Println(fmt.Sprintf("Reapplied migration %s.", migrations[0].Id))
+	} else {
+		fmt.Println(fmt.Sprintf("Reapplied migration %s.", rp[0].Migrations[0].ID))
 	}
 }
@@ -1,8 +1,10 @@
 package migrate
 
 import (
+	"context"
 	"fmt"
-	migrate "github.com/rubenv/sql-migrate"
+
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
 	"github.com/spf13/cobra"
 )
 
@@ -23,20 +25,27 @@ func MigrateSkipRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		panic(err)
 	}
-	db, dialect, err := GetConnection(env)
+	db, _, err := GetConnection(env)
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
 
-	source := migrate.FileMigrationSource{
-		Dir: env.Dir,
+	m, err := migrator.NewFromDir(db, *env)
+	if err != nil {
+		panic(err)
+	}
+	unlock, err := LockMigrations(m)
+	if err != nil {
+		panic(err)
 	}
+	defer unlock()
 
-	n, err := migrate.SkipMax(db, dialect, source, migrate.Up, limit)
+	rp, err := m.Skip(context.Background(), migrator.WithLimit(limit))
 	if err != nil {
 		panic(fmt.Errorf("migration failed: %s", err))
 	}
+	n := len(rp.Migrations)
 	switch n {
 	case 0:
 		fmt.Println("All migrations have already been applied")
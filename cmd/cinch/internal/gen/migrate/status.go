@@ -1,87 +1,127 @@
 package migrate
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/olekukonko/tablewriter"
-	migrate "github.com/rubenv/sql-migrate"
-	"github.com/spf13/cobra"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var migrateStatus = &cobra.Command{
 	Use:   "status",
 	Short: "Show migration status.",
-	Long:  "Show migration status.",
-	Run:   MigrateStatusRun,
+	Long: `Show migration status, an applied migration is modified if its up section is changed after it was applied.
+With --check, exit 1 if there are pending migrations or applied migrations without files.`,
+	Run: MigrateStatusRun,
+}
+
+func init() {
+	migrateStatus.Flags().StringP("output", "o", "text", "Output format: text|json|yaml.")
+	migrateStatus.Flags().Bool("check", false, "Exit 1 if migrations are pending or orphaned.")
 }
 
 type statusRow struct {
-	Id        string
-	Migrated  bool
-	AppliedAt time.Time
+	ID        string     `json:"id" yaml:"id"`
+	Applied   bool       `json:"applied" yaml:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty" yaml:"applied_at,omitempty"`
+	File      string     `json:"file,omitempty" yaml:"file,omitempty"`
+	Orphaned  bool       `json:"orphaned" yaml:"orphaned"`
+	Modified  bool       `json:"modified" yaml:"modified"`
 }
 
 func MigrateStatusRun(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	check, _ := cmd.Flags().GetBool("check")
+	if output != "text" && output != "json" && output != "yaml" {
+		panic(fmt.Sprintf("Unsupported output %s, support text || json || yaml.", output))
+	}
 	ConfigFlags(cmd)
 	env, err := GetEnvironment()
 	if err != nil {
 		panic(err)
 	}
-	db, dialect, err := GetConnection(env)
+	db, _, err := GetConnection(env)
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
 
-	source := migrate.FileMigrationSource{
-		Dir: env.Dir,
-	}
-	migrations, err := source.FindMigrations()
+	m, err := migrator.NewFromDir(db, *env)
 	if err != nil {
 		panic(err)
 	}
-
-	records, err := migrate.GetMigrationRecords(db, dialect)
+	list, err := m.Status(context.Background())
 	if err != nil {
 		panic(err)
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Migration", "Applied"})
-	table.SetColWidth(60)
-
-	rows := make(map[string]*statusRow)
-
-	for _, m := range migrations {
-		rows[m.Id] = &statusRow{
-			Id:       m.Id,
-			Migrated: false,
+	rows := make([]statusRow, 0, len(list))
+	var pending, orphaned int
+	for _, item := range list {
+		row := statusRow{
+			ID:       item.ID,
+			Applied:  item.Applied,
+			Orphaned: item.Orphaned,
+			Modified: item.Modified,
+		}
+		if item.Applied {
+			appliedAt := item.AppliedAt
+			row.AppliedAt = &appliedAt
+		} else {
+			pending++
 		}
+		if item.Orphaned {
+			orphaned++
+		} else {
+			row.File = filepath.Join(env.Dir, item.ID)
+		}
+		rows = append(rows, row)
 	}
 
-	for _, r := range records {
-		if rows[r.Id] == nil {
-			fmt.Println(fmt.Sprintf("Could not find migration file: %v", r.Id))
-			continue
-		}
+	switch output {
+	case "json":
+		b, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(b))
+	case "yaml":
+		b, _ := yaml.Marshal(rows)
+		fmt.Print(string(b))
+	default:
+		renderStatus(rows)
+	}
 
-		rows[r.Id].Migrated = true
-		rows[r.Id].AppliedAt = r.AppliedAt
+	if check && (pending > 0 || orphaned > 0) {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("%d pending migrations, %d orphaned migrations", pending, orphaned))
+		os.Exit(1)
 	}
+}
 
-	for _, m := range migrations {
-		if rows[m.Id] != nil && rows[m.Id].Migrated {
-			table.Append([]string{
-				m.Id,
-				rows[m.Id].AppliedAt.String(),
-			})
-		} else {
-			table.Append([]string{
-				m.Id,
-				"no",
-			})
+func renderStatus(rows []statusRow) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Migration", "Applied"})
+	table.SetColWidth(60)
+
+	for _, row := range rows {
+		applied := "no"
+		if row.AppliedAt != nil {
+			applied = row.AppliedAt.String()
+		}
+		switch {
+		case row.Orphaned:
+			applied += " (no migration file)"
+		case row.Modified:
+			applied += " (modified)"
 		}
+		table.Append([]string{
+			row.ID,
+			applied,
+		})
 	}
 
 	table.Render()
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-cinch/cinch/cmd/cinch/migrator"
+	"github.com/spf13/cobra"
+)
+
+var migrateUnlock = &cobra.Command{
+	Use:   "unlock",
+	Short: "Release the migration lock left by a killed process.",
+	Long: `Release the migration lock left by a killed process, only sqlite3 keeps the lock in a table,
+the lock of mysql and postgres is released when the connection is closed.
+Make sure the holder shown by the lock error is not running before unlock.`,
+	Run: MigrateUnlockRun,
+}
+
+func MigrateUnlockRun(cmd *cobra.Command, args []string) {
+	ConfigFlags(cmd)
+	env, err := GetEnvironment()
+	if err != nil {
+		panic(err)
+	}
+	db, _, err := GetConnection(env)
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	m, err := migrator.NewFromDir(db, *env)
+	if err != nil {
+		panic(err)
+	}
+	released, err := m.ForceUnlock(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	if released {
+		fmt.Println("Released the migration lock")
+	} else {
+		fmt.Println("The migration lock is not held, nothing to do")
+	}
+}
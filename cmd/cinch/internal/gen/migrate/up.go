@@ -16,14 +16,16 @@ func init() {
 	migrateUp.PersistentFlags().IntP("limit", "l", 0, "Limit the number of migrations (0 = unlimited).")
 	migrateUp.PersistentFlags().Int64P("version", "v", -1, "Run migrate up to a specific version, eg: the version number of migration 1_initial.sql is 1.")
 	migrateUp.PersistentFlags().BoolP("dryrun", "d", false, "Don't apply migrations, just print them.")
+	migrateUp.PersistentFlags().Bool("allow-modified", false, "Apply migrations even if an applied migration is modified, the new checksums are recorded.")
 }
 
 func MigrateUpRun(cmd *cobra.Command, args []string) {
 	limit, _ := cmd.Flags().GetInt("limit")
 	version, _ := cmd.Flags().GetInt64("version")
 	dryrun, _ := cmd.Flags().GetBool("dryrun")
+	allowModified, _ := cmd.Flags().GetBool("allow-modified")
 	ConfigFlags(cmd)
-	err := ApplyMigrations(migrate.Up, dryrun, limit, version)
+	err := ApplyMigrations(migrate.Up, dryrun, limit, version, allowModified)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,194 @@
+package migrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+// ErrModified is returned by Up if the up section of an applied migration is modified
+var ErrModified = errors.New("applied migration is modified")
+
+// checksumTableSuffix is the suffix of the table which stores checksums, sql-migrate only stores id and applied_at
+const checksumTableSuffix = "_checksums"
+
+// Checksum returns the sha256 of the up statements of a migration
+func Checksum(statements []string) string {
+	h := sha256.New()
+	for _, item := range statements {
+		h.Write([]byte(strings.TrimSpace(item)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Modified returns the ids of applied migrations whose up section is not the same as when they were applied,
+// migrations applied before checksums were recorded are not checked
+func (m *Migrator) Modified(ctx context.Context) (ids []string, err error) {
+	files, err := m.source.FindMigrations()
+	if err != nil {
+		err = fmt.Errorf("cannot find migrations: %w", err)
+		return
+	}
+	sums, err := m.checksums(ctx)
+	if err != nil {
+		return
+	}
+	return modified(files, sums), nil
+}
+
+func modified(files []*migrate.Migration, sums map[string]string) (ids []string) {
+	for _, f := range files {
+		if sum, ok := sums[f.Id]; ok && sum != Checksum(f.Up) {
+			ids = append(ids, f.Id)
+		}
+	}
+	return
+}
+
+// checkModified returns ErrModified with the modified ids
+func (m *Migrator) checkModified(ctx context.Context) error {
+	ids, err := m.Modified(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("%w: %s", ErrModified, strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// recordChecksums saves the checksums of applied migrations which are new, modified or applied before checksums were recorded
+func (m *Migrator) recordChecksums(ctx context.Context) error {
+	files, err := m.source.FindMigrations()
+	if err != nil {
+		return fmt.Errorf("cannot find migrations: %w", err)
+	}
+	records, err := m.set.GetMigrationRecords(m.db, m.env.Dialect)
+	if err != nil {
+		return fmt.Errorf("cannot get migration records: %w", err)
+	}
+	sums, err := m.checksums(ctx)
+	if err != nil {
+		return err
+	}
+	applied := make(map[string]bool, len(records))
+	for _, r := range records {
+		applied[r.Id] = true
+	}
+	for _, f := range files {
+		sum := Checksum(f.Up)
+		if !applied[f.Id] || sums[f.Id] == sum {
+			continue
+		}
+		err = m.saveChecksum(ctx, f.Id, sum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checksums returns the recorded checksums by id, it is empty if the table does not exist,
+// so that reading status does not change the database
+func (m *Migrator) checksums(ctx context.Context) (sums map[string]string, err error) {
+	sums = make(map[string]string)
+	ok, err := m.checksumTableExists(ctx)
+	if err != nil || !ok {
+		return
+	}
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT %s, %s FROM %s", m.quote("id"), m.quote("checksum"), m.checksumTable()))
+	if err != nil {
+		err = fmt.Errorf("cannot get checksums: %w", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, sum string
+		err = rows.Scan(&id, &sum)
+		if err != nil {
+			return
+		}
+		sums[id] = sum
+	}
+	err = rows.Err()
+	return
+}
+
+// saveChecksum saves the checksum of id, the table is created if not exists
+func (m *Migrator) saveChecksum(ctx context.Context, id, sum string) error {
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s VARCHAR(255) NOT NULL PRIMARY KEY, %s CHAR(64) NOT NULL)", m.checksumTable(), m.quote("id"), m.quote("checksum")))
+	if err != nil {
+		return fmt.Errorf("cannot create checksum table: %w", err)
+	}
+	err = m.deleteChecksum(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (%s, %s)", m.checksumTable(), m.quote("id"), m.quote("checksum"), m.placeholder(1), m.placeholder(2)), id, sum)
+	if err != nil {
+		return fmt.Errorf("cannot save checksum of %s: %w", id, err)
+	}
+	return nil
+}
+
+// deleteChecksum deletes the checksum of id, nothing to do if the table does not exist
+func (m *Migrator) deleteChecksum(ctx context.Context, id string) error {
+	ok, err := m.checksumTableExists(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	_, err = m.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = %s", m.checksumTable(), m.quote("id"), m.placeholder(1)), id)
+	if err != nil {
+		return fmt.Errorf("cannot delete checksum of %s: %w", id, err)
+	}
+	return nil
+}
+
+// checksumTableExists reports whether the checksum table exists without creating it
+func (m *Migrator) checksumTableExists(ctx context.Context) (ok bool, err error) {
+	name := m.env.TableName + checksumTableSuffix
+	var n int
+	switch m.env.Dialect {
+	case "mysql":
+		err = m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?", m.env.SchemaName, name).Scan(&n)
+	case "postgres":
+		err = m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pg_catalog.pg_tables WHERE schemaname = COALESCE(NULLIF($1, ''), CURRENT_SCHEMA()) AND tablename = $2", m.env.SchemaName, name).Scan(&n)
+	default:
+		err = m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	}
+	if err != nil {
+		err = fmt.Errorf("cannot check checksum table: %w", err)
+		return
+	}
+	ok = n > 0
+	return
+}
+
+// checksumTable returns the quoted checksum table next to the migration table
+func (m *Migrator) checksumTable() string {
+	table := m.quote(m.env.TableName + checksumTableSuffix)
+	if m.env.SchemaName != "" {
+		table = m.quote(m.env.SchemaName) + "." + table
+	}
+	return table
+}
+
+func (m *Migrator) quote(name string) string {
+	if m.env.Dialect == "mysql" {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+func (m *Migrator) placeholder(i int) string {
+	if m.env.Dialect == "postgres" {
+		return fmt.Sprintf("$%d", i)
+	}
+	return "?"
+}
@@ -0,0 +1,223 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultLockTimeout is the default time to wait for the migration lock
+const DefaultLockTimeout = time.Minute
+
+// ErrLocked is returned by Lock if the lock is held by another process until timeout
+var ErrLocked = errors.New("migration lock is held by another process")
+
+// lockTableSuffix is the suffix of the lock table of sqlite, which has no session lock
+const lockTableSuffix = "_lock"
+
+// lockPollInterval is the interval of retrying a lock which cannot wait in database
+const lockPollInterval = 500 * time.Millisecond
+
+// Lock takes the migration lock of the migration table, so that only one process migrates at the same time,
+// GET_LOCK is used for mysql, pg_advisory_lock for postgres and a lock table for sqlite,
+// unlock must be called to release the lock, timeout <= 0 is DefaultLockTimeout
+func (m *Migrator) Lock(ctx context.Context, timeout time.Duration) (unlock func() error, err error) {
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	switch m.env.Dialect {
+	case "mysql":
+		return m.lockMysql(ctx, timeout)
+	case "postgres":
+		return m.lockPostgres(ctx, timeout)
+	default:
+		return m.lockSqlite(ctx, timeout)
+	}
+}
+
+// lockName is unique for the migration table of a database
+func (m *Migrator) lockName() string {
+	name := "cinch_migrate:" + m.env.TableName
+	if m.env.SchemaName != "" {
+		name = "cinch_migrate:" + m.env.SchemaName + "." + m.env.TableName
+	}
+	return name
+}
+
+// mysqlLockNameLen is the max length of lock name of GET_LOCK
+const mysqlLockNameLen = 64
+
+// mysqlLockName is lockName, it is hashed if it is longer than GET_LOCK accepts
+func (m *Migrator) mysqlLockName() string {
+	name := m.lockName()
+	if len(name) <= mysqlLockNameLen {
+		return name
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("cinch_migrate:%x", h.Sum64())
+}
+
+// lockHost is the host which is saved in sqlite lock table with pid
+func lockHost() string {
+	host, _ := os.Hostname()
+	return host
+}
+
+// lockMysql uses GET_LOCK which is released when the connection is closed, timeout is rounded up to seconds
+func (m *Migrator) lockMysql(ctx context.Context, timeout time.Duration) (unlock func() error, err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	name := m.mysqlLockName()
+	// GET_LOCK waits in seconds, 0 does not wait, so timeout is rounded up
+	seconds := int((timeout + time.Second - 1) / time.Second)
+	var ok sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, seconds).Scan(&ok)
+	if err != nil || ok.Int64 != 1 {
+		if err == nil {
+			var holder sql.NullString
+			conn.QueryRowContext(ctx, "SELECT CONCAT(p.USER, '@', p.HOST, ' connection ', p.ID) FROM information_schema.PROCESSLIST p WHERE p.ID = IS_USED_LOCK(?)", name).Scan(&holder)
+			err = lockedError(name, timeout, holder.String)
+		} else {
+			err = fmt.Errorf("cannot get lock %s: %w", name, err)
+		}
+		conn.Close()
+		return
+	}
+	unlock = func() error {
+		defer conn.Close()
+		_, e := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		return e
+	}
+	return
+}
+
+// lockPostgres uses pg_try_advisory_lock until timeout, the lock is released when the connection is closed
+func (m *Migrator) lockPostgres(ctx context.Context, timeout time.Duration) (unlock func() error, err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	name := m.lockName()
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	key := int64(h.Sum64())
+	err = poll(ctx, timeout, func() (bool, error) {
+		var ok bool
+		e := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok)
+		return ok, e
+	})
+	if err != nil {
+		if errors.Is(err, ErrLocked) {
+			var holder sql.NullString
+			conn.QueryRowContext(ctx, `SELECT a.usename || '@' || COALESCE(host(a.client_addr), 'local') || ' pid ' || a.pid || ' ' || a.application_name
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1 AND ((l.classid::bigint << 32) | l.objid::bigint) = $1 LIMIT 1`, key).Scan(&holder)
+			err = lockedError(name, timeout, holder.String)
+		} else {
+			err = fmt.Errorf("cannot get lock %s: %w", name, err)
+		}
+		conn.Close()
+		return
+	}
+	unlock = func() error {
+		defer conn.Close()
+		_, e := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		return e
+	}
+	return
+}
+
+// lockSqlite inserts the only row of lock table until timeout, the row records host, pid and acquisition time of the holder,
+// it is left if the process is killed, call ForceUnlock (cinch gen migrate unlock) after checking the holder is not running
+func (m *Migrator) lockSqlite(ctx context.Context, timeout time.Duration) (unlock func() error, err error) {
+	table := m.quote(m.env.TableName + lockTableSuffix)
+	_, err = m.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INTEGER NOT NULL PRIMARY KEY, host TEXT NOT NULL, pid INTEGER NOT NULL, locked_at DATETIME NOT NULL)", table))
+	if err != nil {
+		err = fmt.Errorf("cannot create lock table: %w", err)
+		return
+	}
+	name := m.env.TableName + lockTableSuffix
+	err = poll(ctx, timeout, func() (bool, error) {
+		_, e := m.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, host, pid, locked_at) VALUES (1, ?, ?, ?)", table), lockHost(), os.Getpid(), time.Now().UTC())
+		if e != nil && strings.Contains(e.Error(), "UNIQUE constraint failed") {
+			return false, nil
+		}
+		return e == nil, e
+	})
+	if err != nil {
+		if errors.Is(err, ErrLocked) {
+			var host string
+			var pid int
+			var lockedAt time.Time
+			m.db.QueryRowContext(ctx, fmt.Sprintf("SELECT host, pid, locked_at FROM %s WHERE id = 1", table)).Scan(&host, &pid, &lockedAt)
+			err = lockedError(name, timeout, fmt.Sprintf("%s pid %d since %s, run cinch gen migrate unlock if it is not running", host, pid, lockedAt.Format(time.RFC3339)))
+		} else {
+			err = fmt.Errorf("cannot get lock %s: %w", name, err)
+		}
+		return
+	}
+	unlock = func() error {
+		_, e := m.db.ExecContext(context.Background(), fmt.Sprintf("DELETE FROM %s WHERE id = 1 AND host = ? AND pid = ?", table), lockHost(), os.Getpid())
+		return e
+	}
+	return
+}
+
+// ForceUnlock releases the sqlite lock which is left by a killed process, make sure the holder is not running,
+// the locks of mysql and postgres are released when the connection is closed, so nothing to do for them
+func (m *Migrator) ForceUnlock(ctx context.Context) (released bool, err error) {
+	if m.env.Dialect == "mysql" || m.env.Dialect == "postgres" {
+		return
+	}
+	name := m.env.TableName + lockTableSuffix
+	var n int
+	err = m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	if err != nil || n == 0 {
+		return
+	}
+	res, err := m.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = 1", m.quote(name)))
+	if err != nil {
+		err = fmt.Errorf("cannot release lock %s: %w", name, err)
+		return
+	}
+	n64, _ := res.RowsAffected()
+	released = n64 > 0
+	return
+}
+
+// poll calls try until it returns true, ErrLocked is returned if timeout
+func poll(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := try()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func lockedError(name string, timeout time.Duration, holder string) error {
+	if holder == "" {
+		holder = "unknown"
+	}
+	return fmt.Errorf("%w: cannot get lock %s in %s, held by %s", ErrLocked, name, timeout, holder)
+}
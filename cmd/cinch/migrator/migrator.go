@@ -0,0 +1,363 @@
+// Package migrator runs sql-migrate migrations of a cinch migrate environment,
+// so that a service can apply the migrations embedded in its binary at startup:
+//
+//	//go:embed internal/db/migrations/*.sql
+//	var migrations embed.FS
+//
+//	m, err := migrator.New(db, migrator.Environment{Dialect: "mysql", Dir: "internal/db/migrations"}, migrations)
+//	if err != nil {
+//		return err
+//	}
+//	rp, err := m.Up(ctx)
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"time"
+
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+const (
+	DefaultDialect   = "mysql"
+	DefaultDir       = "internal/db/migrations"
+	DefaultTableName = "schema_migrations"
+)
+
+// Direction is the direction of migrations
+type Direction = migrate.MigrationDirection
+
+const (
+	Up   = migrate.Up
+	Down = migrate.Down
+)
+
+// ErrNothingToRedo is returned by Redo if no migration is applied
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// dialects are the dialects of sql-migrate which cinch supports
+var dialects = []string{"sqlite3", "postgres", "mysql"}
+
+// Environment is a migrate environment of gen.yml, Example:
+//
+//	gen:
+//	  dialect: mysql
+//	  dsn: root:passwd@tcp(127.0.0.1:3306)/db?parseTime=True
+//	  dir: internal/db/migrations
+//	  table: schema_migrations
+type Environment struct {
+	Dialect       string `yaml:"dialect"`
+	DSN           string `yaml:"dsn"`
+	Dir           string `yaml:"dir"`
+	TableName     string `yaml:"table"`
+	SchemaName    string `yaml:"schema"`
+	IgnoreUnknown bool   `yaml:"ignoreunknown"`
+}
+
+// Tables returns the tables which are owned by the migrator, the migration records, checksums and the sqlite lock,
+// they are not a part of the schema of migrations
+func (e Environment) Tables() []string {
+	table := e.TableName
+	if table == "" {
+		table = DefaultTableName
+	}
+	return []string{table, table + checksumTableSuffix, table + lockTableSuffix}
+}
+
+// Migrator applies the migrations of source to db, table and schema of Environment are used instead of sql-migrate globals
+type Migrator struct {
+	db     *sql.DB
+	env    Environment
+	source migrate.MigrationSource
+	set    migrate.MigrationSet
+}
+
+// Migration is a planned or applied migration
+type Migration struct {
+	ID string
+	// Statements are the statements of the direction
+	Statements []string
+}
+
+// Result is the result of Up, Down or Redo
+type Result struct {
+	Direction Direction
+	DryRun    bool
+	// Migrations are applied in order, they are planned only if DryRun
+	Migrations []Migration
+}
+
+// Status is the status of a migration file or record
+type Status struct {
+	ID        string
+	Applied   bool
+	AppliedAt time.Time
+	// Orphaned is a record whose migration file is not found
+	Orphaned bool
+	// Modified is an applied migration whose up section is changed after it was applied
+	Modified bool
+}
+
+// New returns the migrator of migrations in fsys, env.Dir is the directory in fsys, default is the root of fsys,
+// sub directories are not read, so env.Dir must be set if the migrations are embedded with their directory
+func New(db *sql.DB, env Environment, fsys fs.FS) (*Migrator, error) {
+	if env.Dir != "" && env.Dir != "." {
+		sub, err := fs.Sub(fsys, env.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open %s: %w", env.Dir, err)
+		}
+		fsys = sub
+	}
+	return newMigrator(db, env, migrate.HttpFileSystemMigrationSource{FileSystem: http.FS(fsys)})
+}
+
+// NewFromDir returns the migrator of migrations in env.Dir of disk, default is DefaultDir
+func NewFromDir(db *sql.DB, env Environment) (*Migrator, error) {
+	if env.Dir == "" {
+		env.Dir = DefaultDir
+	}
+	return newMigrator(db, env, migrate.FileMigrationSource{Dir: env.Dir})
+}
+
+func newMigrator(db *sql.DB, env Environment, source migrate.MigrationSource) (*Migrator, error) {
+	if env.Dialect == "" {
+		env.Dialect = DefaultDialect
+	}
+	if env.TableName == "" {
+		env.TableName = DefaultTableName
+	}
+	supported := false
+	for _, item := range dialects {
+		supported = supported || item == env.Dialect
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported dialect: %s", env.Dialect)
+	}
+	return &Migrator{
+		db:     db,
+		env:    env,
+		source: source,
+		set: migrate.MigrationSet{
+			TableName:     env.TableName,
+			SchemaName:    env.SchemaName,
+			IgnoreUnknown: env.IgnoreUnknown,
+		},
+	}, nil
+}
+
+// Environment returns the environment with defaults
+func (m *Migrator) Environment() Environment {
+	return m.env
+}
+
+// Up applies pending migrations, ErrModified is returned if an applied migration is modified unless WithAllowModified is set
+func (m *Migrator) Up(ctx context.Context, options ...func(*Options)) (*Result, error) {
+	return m.Apply(ctx, Up, options...)
+}
+
+// Down undoes applied migrations, the limit is 1 unless WithLimit or WithVersion is set
+func (m *Migrator) Down(ctx context.Context, options ...func(*Options)) (*Result, error) {
+	return m.Apply(ctx, Down, append([]func(*Options){WithLimit(1)}, options...)...)
+}
+
+// Apply applies migrations of dir
+func (m *Migrator) Apply(ctx context.Context, dir Direction, options ...func(*Options)) (rp *Result, err error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if dir == Up && !ops.allowModified {
+		err = m.checkModified(ctx)
+		if err != nil {
+			return
+		}
+	}
+	planned, err := m.plan(dir, ops)
+	if err != nil {
+		return
+	}
+	rp = &Result{
+		Direction:  dir,
+		DryRun:     ops.dryRun,
+		Migrations: migrations(planned, dir),
+	}
+	if ops.dryRun {
+		return
+	}
+	var n int
+	if ops.version >= 0 {
+		n, err = m.set.ExecVersionContext(ctx, m.db, m.env.Dialect, m.source, dir, ops.version)
+	} else {
+		n, err = m.set.ExecMaxContext(ctx, m.db, m.env.Dialect, m.source, dir, ops.limit)
+	}
+	// the migrations before the failed one are applied
+	if n < len(rp.Migrations) {
+		rp.Migrations = rp.Migrations[:n]
+	}
+	if err != nil {
+		err = fmt.Errorf("migration failed: %w", err)
+	}
+	sumErr := m.applyChecksums(ctx, rp)
+	if err == nil {
+		err = sumErr
+	}
+	return
+}
+
+// applyChecksums records the checksums of applied up migrations and deletes the checksums of down migrations
+func (m *Migrator) applyChecksums(ctx context.Context, rp *Result) error {
+	if rp.Direction == Up {
+		return m.recordChecksums(ctx)
+	}
+	for _, item := range rp.Migrations {
+		err := m.deleteChecksum(ctx, item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redo undoes the last applied migration and applies it again,
+// ErrModified is returned before the undo if an applied migration is modified unless WithAllowModified
+func (m *Migrator) Redo(ctx context.Context, options ...func(*Options)) (rp []*Result, err error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	if !ops.allowModified {
+		err = m.checkModified(ctx)
+		if err != nil {
+			return
+		}
+	}
+	planned, _, err := m.set.PlanMigration(m.db, m.env.Dialect, m.source, Down, 1)
+	if err != nil {
+		err = fmt.Errorf("cannot plan migration: %w", err)
+		return
+	}
+	if len(planned) == 0 {
+		err = ErrNothingToRedo
+		return
+	}
+	if ops.dryRun {
+		rp = []*Result{
+			{Direction: Down, DryRun: true, Migrations: migrations(planned, Down)},
+			{Direction: Up, DryRun: true, Migrations: migrations(planned, Up)},
+		}
+		return
+	}
+	for _, dir := range []Direction{Down, Up} {
+		var item *Result
+		item, err = m.Apply(ctx, dir, WithLimit(1), WithAllowModified(ops.allowModified))
+		if item != nil {
+			rp = append(rp, item)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Skip marks the up migrations as applied without running them, their checksums are recorded,
+// WithLimit limits the number of migrations
+func (m *Migrator) Skip(ctx context.Context, options ...func(*Options)) (rp *Result, err error) {
+	ops := getOptionsOrSetDefault(nil)
+	for _, f := range options {
+		f(ops)
+	}
+	planned, dbMap, err := m.set.PlanMigration(m.db, m.env.Dialect, m.source, Up, ops.limit)
+	if err != nil {
+		err = fmt.Errorf("cannot plan migration: %w", err)
+		return
+	}
+	rp = &Result{Direction: Up}
+	for _, item := range planned {
+		err = dbMap.WithContext(ctx).Insert(&migrate.MigrationRecord{Id: item.Id, AppliedAt: time.Now()})
+		if err != nil {
+			err = fmt.Errorf("cannot skip migration %s: %w", item.Id, err)
+			break
+		}
+		rp.Migrations = append(rp.Migrations, Migration{ID: item.Id, Statements: item.Up})
+	}
+	sumErr := m.recordChecksums(ctx)
+	if err == nil {
+		err = sumErr
+	}
+	return
+}
+
+// Status returns the migration files and records without files in order
+func (m *Migrator) Status(ctx context.Context) (list []Status, err error) {
+	files, err := m.source.FindMigrations()
+	if err != nil {
+		err = fmt.Errorf("cannot find migrations: %w", err)
+		return
+	}
+	records, err := m.set.GetMigrationRecords(m.db, m.env.Dialect)
+	if err != nil {
+		err = fmt.Errorf("cannot get migration records: %w", err)
+		return
+	}
+	sums, err := m.checksums(ctx)
+	if err != nil {
+		return
+	}
+	changed := make(map[string]bool)
+	for _, id := range modified(files, sums) {
+		changed[id] = true
+	}
+	applied := make(map[string]time.Time, len(records))
+	for _, r := range records {
+		applied[r.Id] = r.AppliedAt
+	}
+	for _, f := range files {
+		item := Status{ID: f.Id, Modified: changed[f.Id]}
+		if at, ok := applied[f.Id]; ok {
+			item.Applied = true
+			item.AppliedAt = at
+			delete(applied, f.Id)
+		}
+		list = append(list, item)
+	}
+	orphaned := make([]Status, 0, len(applied))
+	for id, at := range applied {
+		orphaned = append(orphaned, Status{ID: id, Applied: true, AppliedAt: at, Orphaned: true})
+	}
+	sort.Slice(orphaned, func(i, j int) bool {
+		return orphaned[i].ID < orphaned[j].ID
+	})
+	list = append(list, orphaned...)
+	return
+}
+
+func (m *Migrator) plan(dir Direction, ops *Options) (planned []*migrate.PlannedMigration, err error) {
+	if ops.version >= 0 {
+		planned, _, err = m.set.PlanMigrationToVersion(m.db, m.env.Dialect, m.source, dir, ops.version)
+	} else {
+		planned, _, err = m.set.PlanMigration(m.db, m.env.Dialect, m.source, dir, ops.limit)
+	}
+	if err != nil {
+		err = fmt.Errorf("cannot plan migration: %w", err)
+	}
+	return
+}
+
+func migrations(planned []*migrate.PlannedMigration, dir Direction) []Migration {
+	list := make([]Migration, 0, len(planned))
+	for _, item := range planned {
+		statements := item.Up
+		if dir == Down {
+			statements = item.Down
+		}
+		list = append(list, Migration{ID: item.Id, Statements: statements})
+	}
+	return list
+}
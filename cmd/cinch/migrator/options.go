@@ -0,0 +1,46 @@
+package migrator
+
+type Options struct {
+	limit   int
+	version int64
+	dryRun  bool
+	// allowModified applies up migrations even if an applied migration is modified
+	allowModified bool
+}
+
+// WithLimit limits the number of migrations, 0 is unlimited
+func WithLimit(limit int) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).limit = limit
+	}
+}
+
+// WithVersion migrates up or down to version, Example: the version of 1_initial.sql is 1
+func WithVersion(version int64) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).version = version
+	}
+}
+
+// WithDryRun plans migrations without applying them, the statements are in Result
+func WithDryRun(flag bool) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).dryRun = flag
+	}
+}
+
+// WithAllowModified applies up migrations even if the up section of an applied migration is modified, the new checksums are recorded
+func WithAllowModified(flag bool) func(*Options) {
+	return func(options *Options) {
+		getOptionsOrSetDefault(options).allowModified = flag
+	}
+}
+
+func getOptionsOrSetDefault(options *Options) *Options {
+	if options == nil {
+		return &Options{
+			version: -1,
+		}
+	}
+	return options
+}